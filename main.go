@@ -1,24 +1,92 @@
 package main
 
 import (
+	"context"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"go-ambassador/src/export"
+	"go-ambassador/src/mail"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/payments"
+	"go-ambassador/src/routes"
+	"go-ambassador/src/storage"
+	"go-ambassador/src/util"
 	"log"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 )
 
-func main() {
-	_, err := gorm.Open(mysql.Open("root:root@/tcp(db:3306)/ambassador"), &gorm.Config{})
+// shutdownTimeout bounds how long in-flight requests may take after a stop signal
+const shutdownTimeout = 10 * time.Second
 
+func main() {
+	// Load and validate configuration before touching any external service
+	cfg, err := config.Load()
 	if err != nil {
-		panic("failed to connect database")
+		log.Fatal(err)
+	}
+
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatalf("database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.AutoMigrate(); err != nil {
+		log.Fatalf("database migration: %v", err)
+	}
+
+	if err := database.SeedPermissions(); err != nil {
+		log.Fatalf("database seed: %v", err)
+	}
+
+	if err := database.SeedAdmin(cfg.Admin); err != nil {
+		log.Fatalf("admin seed: %v", err)
 	}
-	app := fiber.New()
 
-	app.Get("/", func(c fiber.Ctx) error {
-		return c.SendString("Hello, World 👋!")
+	database.SetupRedis(cfg.Redis)
+	cache.Setup(cfg.Cache, database.Cache)
+	payments.Setup(cfg.Payments)
+	util.SetupJWT(cfg.JWT)
+	util.SetupCookies(cfg.Cookie)
+	mail.Setup(cfg.Mail, cfg.AppURL)
+	storage.Setup(cfg.Storage)
+	export.Setup(cfg.Exports)
+
+	// Errors returned by handlers are rendered as a single JSON envelope
+	app := fiber.New(fiber.Config{
+		AppName:      "go-ambassador",
+		ErrorHandler: middlewares.ErrorHandler,
 	})
 
-	log.Fatal(app.Listen(":3000"))
+	routes.Setup(app)
+
+	// Stop accepting requests on SIGINT/SIGTERM and let in-flight ones finish
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Export jobs run until the stop signal; an interrupted job is queued again
+	workers := export.StartWorkers(ctx, cfg.Exports.Workers)
+
+	go func() {
+		<-ctx.Done()
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	if err := app.Listen(cfg.ListenAddr); err != nil {
+		log.Printf("server: %v", err)
+	}
+
+	// Listen also returns on startup errors, so make sure the workers stop
+	stop()
+	workers.Wait()
+
+	if err := database.Cache.Close(); err != nil {
+		log.Printf("redis: %v", err)
+	}
 }
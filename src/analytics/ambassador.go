@@ -0,0 +1,211 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"go-ambassador/src/models"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LinkStats is the performance of one of an ambassador's links
+// Checkouts counts every order started through the link and Orders only the
+// paid ones; ConversionRate is their ratio
+type LinkStats struct {
+	Id             uint    `json:"id"`
+	Code           string  `json:"code"`
+	Checkouts      int64   `json:"checkouts"`
+	Orders         int64   `json:"orders"`
+	Revenue        float64 `json:"revenue"`
+	Commission     float64 `json:"commission"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// StatsTotals are the performance of all of an ambassador's links
+type StatsTotals struct {
+	Checkouts      int64   `json:"checkouts"`
+	Orders         int64   `json:"orders"`
+	Revenue        float64 `json:"revenue"`
+	Commission     float64 `json:"commission"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// StatsPoint is the paid orders and earnings of one period
+type StatsPoint struct {
+	Period     string  `json:"period"`
+	Orders     int64   `json:"orders"`
+	Revenue    float64 `json:"revenue"`
+	Commission float64 `json:"commission"`
+}
+
+// AmbassadorStats is the earnings dashboard of one ambassador
+// Every figure covers the orders created within the range
+type AmbassadorStats struct {
+	Granularity string       `json:"granularity"`
+	Timezone    string       `json:"timezone"`
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	Totals      StatsTotals  `json:"totals"`
+	Links       []LinkStats  `json:"links"`
+	Series      []StatsPoint `json:"series"`
+}
+
+// paidOrderRow is the revenue and commission of one paid order
+type paidOrderRow struct {
+	Code       string
+	CreateAt   time.Time
+	Revenue    float64
+	Commission float64
+}
+
+// checkoutRow is the number of orders started through one link
+type checkoutRow struct {
+	Code      string
+	Checkouts int64
+}
+
+// Ambassador builds the stats of the links owned by userId
+// Every query is restricted to the user's own links and orders, so the result
+// never includes another ambassador's data
+func Ambassador(ctx context.Context, db *gorm.DB, userId uint, r Range) (*AmbassadorStats, error) {
+	periods := r.Periods()
+	if len(periods) > MaxPeriods {
+		return nil, fmt.Errorf("analytics: %d periods exceed the maximum of %d", len(periods), MaxPeriods)
+	}
+	positions := index(periods)
+
+	db = db.WithContext(ctx)
+
+	stats := &AmbassadorStats{
+		Granularity: r.Granularity,
+		Timezone:    r.Location.String(),
+		From:        r.From.Format(time.DateOnly),
+		To:          r.To.AddDate(0, 0, -1).Format(time.DateOnly),
+		Links:       []LinkStats{},
+		Series:      make([]StatsPoint, len(periods)),
+	}
+
+	for i, period := range periods {
+		stats.Series[i].Period = period.Format(time.DateOnly)
+	}
+
+	var links []models.Link
+	if err := db.Where("user_id = ?", userId).Order("id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	if len(links) == 0 {
+		return stats, nil
+	}
+
+	codes := make([]string, len(links))
+	byCode := make(map[string]*LinkStats, len(links))
+
+	stats.Links = make([]LinkStats, len(links))
+	for i, link := range links {
+		codes[i] = link.Code
+		stats.Links[i] = LinkStats{Id: link.Id, Code: link.Code}
+		byCode[link.Code] = &stats.Links[i]
+	}
+
+	// Both the owner and the code are checked, an order only counts for the
+	// ambassador whose link it was placed through
+	orders := func() *gorm.DB {
+		return db.Table("orders o").
+			Where("o.user_id = ? AND o.code IN ?", userId, codes).
+			Where("o.create_at >= ? AND o.create_at < ?", r.From.UTC(), r.To.UTC())
+	}
+
+	var checkouts []checkoutRow
+	err := orders().
+		Select("o.code, COUNT(*) AS checkouts").
+		Group("o.code").
+		Scan(&checkouts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range checkouts {
+		if link, ok := byCode[row.Code]; ok {
+			link.Checkouts = row.Checkouts
+		}
+	}
+
+	// One row per paid order; periods are assigned in Go for the same
+	// portability reasons as Sales
+	rows, err := orders().
+		Select("o.code, o.create_at, SUM(oi.price * oi.quantity) AS revenue, SUM(oi.ambassador_revenue) AS commission").
+		Joins("JOIN order_items oi ON oi.order_id = o.id").
+		Where("o.status = ?", models.OrderStatusPaid).
+		Group("o.id, o.code, o.create_at").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row paidOrderRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, err
+		}
+
+		// MySQL compares codes case-insensitively; skip anything that is not
+		// exactly one of the links
+		link, ok := byCode[row.Code]
+		if !ok {
+			continue
+		}
+		link.Orders++
+		link.Revenue += row.Revenue
+		link.Commission += row.Commission
+
+		if period, ok := positions[truncate(row.CreateAt, r.Granularity, r.Location).Unix()]; ok {
+			point := &stats.Series[period]
+			point.Orders++
+			point.Revenue += row.Revenue
+			point.Commission += row.Commission
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range stats.Links {
+		link := &stats.Links[i]
+
+		stats.Totals.Checkouts += link.Checkouts
+		stats.Totals.Orders += link.Orders
+		stats.Totals.Revenue += link.Revenue
+		stats.Totals.Commission += link.Commission
+
+		link.Revenue = round(link.Revenue)
+		link.Commission = round(link.Commission)
+		link.ConversionRate = rate(link.Orders, link.Checkouts)
+	}
+
+	stats.Totals.Revenue = round(stats.Totals.Revenue)
+	stats.Totals.Commission = round(stats.Totals.Commission)
+	stats.Totals.ConversionRate = rate(stats.Totals.Orders, stats.Totals.Checkouts)
+
+	for i := range stats.Series {
+		stats.Series[i].Revenue = round(stats.Series[i].Revenue)
+		stats.Series[i].Commission = round(stats.Series[i].Commission)
+	}
+
+	return stats, nil
+}
+
+// rate returns the share of checkouts that were paid, between 0 and 1 and
+// rounded to four decimals, or zero without checkouts
+func rate(orders int64, checkouts int64) float64 {
+	if checkouts == 0 {
+		return 0
+	}
+
+	// A single rounding step; dividing a rounded percentage by 100 brings
+	// back float noise such as 0.6667000000000001
+	return math.Round(float64(orders)/float64(checkouts)*10000) / 10000
+}
@@ -0,0 +1,94 @@
+package analytics_test
+
+import (
+	"context"
+	"go-ambassador/src/analytics"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// linkOrder stores an order placed through the link at createAt
+func linkOrder(t *testing.T, db *gorm.DB, link models.Link, createAt time.Time, status string, items ...models.OrderItem) {
+	t.Helper()
+
+	order := models.Order{
+		UserId:     link.UserId,
+		Code:       link.Code,
+		Status:     status,
+		CreateAt:   createAt.UTC(),
+		OrderItems: items,
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAmbassadorStats(t *testing.T) {
+	db := testutil.DB(t)
+
+	links := []models.Link{{Code: "alice1", UserId: 1}, {Code: "alice2", UserId: 1}, {Code: "bob1", UserId: 2}}
+	if err := db.Omit(clause.Associations).Create(&links).Error; err != nil {
+		t.Fatal(err)
+	}
+	alice1, alice2, bob1 := links[0], links[1], links[2]
+
+	commission := func(price float64, quantity uint) models.OrderItem {
+		return models.NewOrderItem(models.Product{Id: 1, Title: "Mug", Price: price}, quantity)
+	}
+
+	linkOrder(t, db, alice1, at(t, "2026-03-01T10:00:00Z"), models.OrderStatusPaid, commission(10, 2))
+	linkOrder(t, db, alice1, at(t, "2026-03-03T10:00:00Z"), models.OrderStatusPaid, commission(10, 1))
+	linkOrder(t, db, alice1, at(t, "2026-03-03T11:00:00Z"), models.OrderStatusPending, commission(10, 1))
+	linkOrder(t, db, alice2, at(t, "2026-03-02T10:00:00Z"), models.OrderStatusCancelled, commission(10, 1))
+	// Another ambassador's sales and sales outside the range are left out
+	linkOrder(t, db, bob1, at(t, "2026-03-01T10:00:00Z"), models.OrderStatusPaid, commission(100, 1))
+	linkOrder(t, db, alice1, at(t, "2026-03-04T10:00:00Z"), models.OrderStatusPaid, commission(100, 1))
+
+	stats, err := analytics.Ambassador(context.Background(), db, 1,
+		dayRange(analytics.GranularityDay, time.UTC, "2026-03-01", "2026-03-03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantLinks := []analytics.LinkStats{
+		{Id: alice1.Id, Code: "alice1", Checkouts: 3, Orders: 2, Revenue: 30, Commission: 3, ConversionRate: 0.6667},
+		{Id: alice2.Id, Code: "alice2", Checkouts: 1},
+	}
+	if !reflect.DeepEqual(stats.Links, wantLinks) {
+		t.Errorf("links %+v, want %+v", stats.Links, wantLinks)
+	}
+
+	wantTotals := analytics.StatsTotals{Checkouts: 4, Orders: 2, Revenue: 30, Commission: 3, ConversionRate: 0.5}
+	if stats.Totals != wantTotals {
+		t.Errorf("totals %+v, want %+v", stats.Totals, wantTotals)
+	}
+
+	wantSeries := []analytics.StatsPoint{
+		{Period: "2026-03-01", Orders: 1, Revenue: 20, Commission: 2},
+		{Period: "2026-03-02"},
+		{Period: "2026-03-03", Orders: 1, Revenue: 10, Commission: 1},
+	}
+	if !reflect.DeepEqual(stats.Series, wantSeries) {
+		t.Errorf("series %+v, want %+v", stats.Series, wantSeries)
+	}
+}
+
+func TestAmbassadorStatsWithoutLinks(t *testing.T) {
+	db := testutil.DB(t)
+
+	stats, err := analytics.Ambassador(context.Background(), db, 1,
+		dayRange(analytics.GranularityWeek, time.UTC, "2026-03-02", "2026-03-08"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stats.Links) != 0 || len(stats.Series) != 1 || stats.Series[0] != (analytics.StatsPoint{Period: "2026-03-02"}) {
+		t.Errorf("stats %+v, want one empty week and no links", stats)
+	}
+}
@@ -0,0 +1,322 @@
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"go-ambassador/src/models"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Granularities of the sales series
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// Breakdowns split the sales series by a dimension
+const (
+	BreakdownNone       = ""
+	BreakdownProduct    = "product"
+	BreakdownAmbassador = "ambassador"
+)
+
+// MaxPeriods is the largest number of periods a report may span
+const MaxPeriods = 400
+
+// Range is the time span of a report and how it is divided into periods
+type Range struct {
+	Granularity string
+	// Location is the time zone periods are aligned to
+	Location *time.Location
+	// From is the start of the first day and To the start of the day after the
+	// last one, both in Location
+	From time.Time
+	To   time.Time
+}
+
+// Periods returns the start of every period of the range
+func (r Range) Periods() []time.Time {
+	return Periods(r.Granularity, r.From, r.To, r.Location)
+}
+
+// Key identifies the range in cache keys
+func (r Range) Key() string {
+	return fmt.Sprintf("%s:%s:%s:%s", r.Granularity, r.Location, r.From.Format(time.DateOnly), r.To.Format(time.DateOnly))
+}
+
+// index maps the Unix time of each period start to its position
+// time.Time values are not safe map keys
+func index(periods []time.Time) map[int64]int {
+	positions := make(map[int64]int, len(periods))
+	for i, period := range periods {
+		positions[period.Unix()] = i
+	}
+
+	return positions
+}
+
+// SalesOptions selects the orders and the shape of a sales report
+type SalesOptions struct {
+	Range
+	Breakdown string
+}
+
+// Point is the sales of one period
+type Point struct {
+	Period            string  `json:"period"`
+	Revenue           float64 `json:"revenue"`
+	Orders            int64   `json:"orders"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// Totals are the sales over the whole range
+type Totals struct {
+	Revenue           float64 `json:"revenue"`
+	Orders            int64   `json:"orders"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
+
+// Group is the sales of one product or ambassador
+type Group struct {
+	Key    string  `json:"key"`
+	Label  string  `json:"label"`
+	Totals Totals  `json:"totals"`
+	Series []Point `json:"series"`
+}
+
+// SalesReport is the sales of paid orders per period
+// Every period of the range is present, periods without sales are zero
+type SalesReport struct {
+	Granularity string  `json:"granularity"`
+	Timezone    string  `json:"timezone"`
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	Totals      Totals  `json:"totals"`
+	Series      []Point `json:"series"`
+	Breakdown   []Group `json:"breakdown,omitempty"`
+}
+
+// saleRow is one order item of a paid order
+type saleRow struct {
+	OrderId         uint
+	CreateAt        time.Time
+	UserId          uint
+	AmbassadorEmail string
+	ProductId       uint
+	ProductTitle    string
+	Revenue         float64
+}
+
+// accumulator sums revenue and counts distinct orders per period
+type accumulator struct {
+	revenue   []float64
+	orders    []int64
+	lastOrder []uint
+}
+
+// newAccumulator creates an accumulator for periods periods
+func newAccumulator(periods int) *accumulator {
+	return &accumulator{
+		revenue:   make([]float64, periods),
+		orders:    make([]int64, periods),
+		lastOrder: make([]uint, periods),
+	}
+}
+
+// add records the revenue of one item; rows arrive ordered by order ID, so an
+// order is counted the first time one of its items is seen in the period
+func (a *accumulator) add(period int, orderId uint, revenue float64) {
+	a.revenue[period] += revenue
+
+	if a.lastOrder[period] != orderId {
+		a.lastOrder[period] = orderId
+		a.orders[period]++
+	}
+}
+
+// Sales builds the sales report of paid orders selected by options
+// Rows are bucketed in Go rather than with DATE_FORMAT or CONVERT_TZ, so the
+// same query runs on MySQL and SQLite and any time zone can be used without
+// loading MySQL's time zone tables
+func Sales(ctx context.Context, db *gorm.DB, options SalesOptions) (*SalesReport, error) {
+	periods := options.Periods()
+	if len(periods) > MaxPeriods {
+		return nil, fmt.Errorf("analytics: %d periods exceed the maximum of %d", len(periods), MaxPeriods)
+	}
+	positions := index(periods)
+
+	rows, err := db.WithContext(ctx).
+		Table("orders o").
+		Select("o.id AS order_id, o.create_at, o.user_id, o.ambassador_email, oi.product_id, oi.product_title, oi.price * oi.quantity AS revenue").
+		Joins("JOIN order_items oi ON oi.order_id = o.id").
+		Where("o.status = ? AND o.create_at >= ? AND o.create_at < ?", models.OrderStatusPaid, options.From.UTC(), options.To.UTC()).
+		Order("o.id").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	total := newAccumulator(len(periods))
+	groups := make(map[string]*accumulator)
+	labels := make(map[string]string)
+
+	for rows.Next() {
+		var row saleRow
+		if err := db.ScanRows(rows, &row); err != nil {
+			return nil, err
+		}
+
+		period, ok := positions[truncate(row.CreateAt, options.Granularity, options.Location).Unix()]
+		if !ok {
+			continue
+		}
+
+		total.add(period, row.OrderId, row.Revenue)
+
+		key, label := groupKey(row, options.Breakdown)
+		if key == "" {
+			continue
+		}
+
+		group, ok := groups[key]
+		if !ok {
+			group = newAccumulator(len(periods))
+			groups[key] = group
+		}
+		group.add(period, row.OrderId, row.Revenue)
+
+		// Rows arrive in order, so a renamed product is labelled with the
+		// title of its latest sale
+		labels[key] = label
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &SalesReport{
+		Granularity: options.Granularity,
+		Timezone:    options.Location.String(),
+		From:        options.From.Format(time.DateOnly),
+		To:          options.To.AddDate(0, 0, -1).Format(time.DateOnly),
+	}
+	report.Series, report.Totals = series(periods, total)
+
+	for key, group := range groups {
+		points, totals := series(periods, group)
+		report.Breakdown = append(report.Breakdown, Group{Key: key, Label: labels[key], Totals: totals, Series: points})
+	}
+
+	// Largest revenue first; the key keeps equal revenues in a stable order
+	sort.Slice(report.Breakdown, func(i, j int) bool {
+		a, b := report.Breakdown[i], report.Breakdown[j]
+		if a.Totals.Revenue != b.Totals.Revenue {
+			return a.Totals.Revenue > b.Totals.Revenue
+		}
+		return a.Key < b.Key
+	})
+
+	return report, nil
+}
+
+// Periods returns the start of every period overlapping [from, to)
+func Periods(granularity string, from time.Time, to time.Time, location *time.Location) []time.Time {
+	var periods []time.Time
+
+	for period := truncate(from, granularity, location); period.Before(to); period = next(period, granularity) {
+		periods = append(periods, period)
+	}
+
+	return periods
+}
+
+// truncate returns the start of the period containing t in location
+// Weeks start on Monday
+func truncate(t time.Time, granularity string, location *time.Location) time.Time {
+	t = t.In(location)
+	year, month, day := t.Date()
+
+	switch granularity {
+	case GranularityWeek:
+		monday := day - (int(t.Weekday())+6)%7
+		return time.Date(year, month, monday, 0, 0, 0, 0, location)
+	case GranularityMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, location)
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, location)
+	}
+}
+
+// next returns the start of the period after period
+// AddDate keeps periods aligned to midnight across daylight saving changes
+func next(period time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeek:
+		return period.AddDate(0, 0, 7)
+	case GranularityMonth:
+		return period.AddDate(0, 1, 0)
+	default:
+		return period.AddDate(0, 0, 1)
+	}
+}
+
+// groupKey returns the breakdown key and label of row
+// Products are grouped by ID so a rename does not split a product and two
+// products with the same title stay apart; items recorded before the product
+// ID was stored fall back to their title
+func groupKey(row saleRow, breakdown string) (string, string) {
+	switch breakdown {
+	case BreakdownProduct:
+		if row.ProductId == 0 {
+			return "title:" + row.ProductTitle, row.ProductTitle
+		}
+		return strconv.Itoa(int(row.ProductId)), row.ProductTitle
+	case BreakdownAmbassador:
+		return strconv.Itoa(int(row.UserId)), row.AmbassadorEmail
+	default:
+		return "", ""
+	}
+}
+
+// series converts the accumulated periods to points and sums them
+func series(periods []time.Time, a *accumulator) ([]Point, Totals) {
+	points := make([]Point, len(periods))
+	var totals Totals
+
+	for i, period := range periods {
+		points[i] = Point{
+			Period:            period.Format(time.DateOnly),
+			Revenue:           round(a.revenue[i]),
+			Orders:            a.orders[i],
+			AverageOrderValue: average(a.revenue[i], a.orders[i]),
+		}
+
+		totals.Revenue += a.revenue[i]
+		totals.Orders += a.orders[i]
+	}
+
+	totals.AverageOrderValue = average(totals.Revenue, totals.Orders)
+	totals.Revenue = round(totals.Revenue)
+
+	return points, totals
+}
+
+// average returns revenue per order, or zero without orders
+func average(revenue float64, orders int64) float64 {
+	if orders == 0 {
+		return 0
+	}
+
+	return round(revenue / float64(orders))
+}
+
+// round rounds an amount to cents, hiding float summation noise
+func round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,304 @@
+package analytics_test
+
+import (
+	"context"
+	"go-ambassador/src/analytics"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// createOrder stores an order created at createAt with the items
+// Times are stored in UTC, as the MySQL connection does
+func createOrder(t *testing.T, db *gorm.DB, createAt time.Time, status string, userId uint, items ...models.OrderItem) models.Order {
+	t.Helper()
+
+	order := models.Order{
+		UserId:          userId,
+		Code:            "code",
+		AmbassadorEmail: "ambassador@example.com",
+		Status:          status,
+		CreateAt:        createAt.UTC(),
+		OrderItems:      items,
+	}
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return order
+}
+
+// item is a line of quantity times price of the product
+func item(productId uint, title string, price float64, quantity uint) models.OrderItem {
+	return models.OrderItem{ProductId: productId, ProductTitle: title, Price: price, Quantity: quantity}
+}
+
+// dayRange covers the days from first to last inclusive in location
+func dayRange(granularity string, location *time.Location, first string, last string) analytics.Range {
+	from, _ := time.ParseInLocation(time.DateOnly, first, location)
+	to, _ := time.ParseInLocation(time.DateOnly, last, location)
+
+	return analytics.Range{Granularity: granularity, Location: location, From: from, To: to.AddDate(0, 0, 1)}
+}
+
+// at parses an RFC 3339 timestamp
+func at(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+// location loads an IANA time zone
+func location(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("time zone %s: %v", name, err)
+	}
+
+	return loc
+}
+
+func TestSalesFillsEmptyPeriods(t *testing.T) {
+	db := testutil.DB(t)
+
+	createOrder(t, db, at(t, "2026-03-01T09:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 2), item(2, "Shirt", 5, 1))
+	createOrder(t, db, at(t, "2026-03-01T18:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 1))
+	createOrder(t, db, at(t, "2026-03-03T12:00:00Z"), models.OrderStatusPaid, 1, item(2, "Shirt", 5, 1))
+	// Only paid orders inside the range count
+	createOrder(t, db, at(t, "2026-03-02T12:00:00Z"), models.OrderStatusPending, 1, item(1, "Mug", 10, 1))
+	createOrder(t, db, at(t, "2026-03-04T12:00:00Z"), models.OrderStatusRefunded, 1, item(1, "Mug", 10, 1))
+	createOrder(t, db, at(t, "2026-02-28T23:59:59Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 1))
+	createOrder(t, db, at(t, "2026-03-06T00:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 1))
+
+	report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{
+		Range: dayRange(analytics.GranularityDay, time.UTC, "2026-03-01", "2026-03-05"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []analytics.Point{
+		{Period: "2026-03-01", Revenue: 35, Orders: 2, AverageOrderValue: 17.5},
+		{Period: "2026-03-02"},
+		{Period: "2026-03-03", Revenue: 5, Orders: 1, AverageOrderValue: 5},
+		{Period: "2026-03-04"},
+		{Period: "2026-03-05"},
+	}
+	if !reflect.DeepEqual(report.Series, want) {
+		t.Errorf("series %+v, want %+v", report.Series, want)
+	}
+
+	wantTotals := analytics.Totals{Revenue: 40, Orders: 3, AverageOrderValue: 13.33}
+	if report.Totals != wantTotals {
+		t.Errorf("totals %+v, want %+v", report.Totals, wantTotals)
+	}
+
+	if report.From != "2026-03-01" || report.To != "2026-03-05" || report.Timezone != "UTC" {
+		t.Errorf("range %s to %s in %s", report.From, report.To, report.Timezone)
+	}
+}
+
+func TestSalesWithoutOrders(t *testing.T) {
+	db := testutil.DB(t)
+
+	report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{
+		Range:     dayRange(analytics.GranularityWeek, time.UTC, "2026-03-02", "2026-03-15"),
+		Breakdown: analytics.BreakdownProduct,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []analytics.Point{{Period: "2026-03-02"}, {Period: "2026-03-09"}}
+	if !reflect.DeepEqual(report.Series, want) || report.Breakdown != nil {
+		t.Errorf("series %+v and breakdown %+v, want two empty weeks", report.Series, report.Breakdown)
+	}
+}
+
+func TestSalesGranularity(t *testing.T) {
+	db := testutil.DB(t)
+
+	// Sunday, Monday and the next month
+	createOrder(t, db, at(t, "2026-03-01T12:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 1, 1))
+	createOrder(t, db, at(t, "2026-03-02T12:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 2, 1))
+	createOrder(t, db, at(t, "2026-04-30T12:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 4, 1))
+
+	tests := []struct {
+		granularity string
+		want        []analytics.Point
+	}{
+		{
+			// Weeks start on Monday, the first one begins before the range
+			granularity: analytics.GranularityWeek,
+			want: []analytics.Point{
+				{Period: "2026-02-23", Revenue: 1, Orders: 1, AverageOrderValue: 1},
+				{Period: "2026-03-02", Revenue: 2, Orders: 1, AverageOrderValue: 2},
+				{Period: "2026-03-09"},
+			},
+		},
+		{
+			granularity: analytics.GranularityMonth,
+			want: []analytics.Point{
+				{Period: "2026-03-01", Revenue: 3, Orders: 2, AverageOrderValue: 1.5},
+				{Period: "2026-04-01", Revenue: 4, Orders: 1, AverageOrderValue: 4},
+			},
+		},
+	}
+
+	ranges := map[string]analytics.Range{
+		analytics.GranularityWeek:  dayRange(analytics.GranularityWeek, time.UTC, "2026-03-01", "2026-03-15"),
+		analytics.GranularityMonth: dayRange(analytics.GranularityMonth, time.UTC, "2026-03-01", "2026-04-30"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granularity, func(t *testing.T) {
+			report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{Range: ranges[tt.granularity]})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(report.Series, tt.want) {
+				t.Errorf("series %+v, want %+v", report.Series, tt.want)
+			}
+		})
+	}
+}
+
+func TestSalesTimeZones(t *testing.T) {
+	db := testutil.DB(t)
+
+	// 03:00 UTC on March 2nd is still March 1st in New York
+	createOrder(t, db, at(t, "2026-03-02T03:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 1))
+	// 23:30 UTC on March 29th is 01:30 on March 30th in Berlin, after the
+	// switch to summer time
+	createOrder(t, db, at(t, "2026-03-29T23:30:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 20, 1))
+
+	tests := []struct {
+		location *time.Location
+		first    string
+		last     string
+		want     map[string]float64
+	}{
+		{time.UTC, "2026-03-01", "2026-03-02", map[string]float64{"2026-03-02": 10}},
+		{location(t, "America/New_York"), "2026-03-01", "2026-03-02", map[string]float64{"2026-03-01": 10}},
+		{location(t, "Europe/Berlin"), "2026-03-28", "2026-03-31", map[string]float64{"2026-03-30": 20}},
+		{time.UTC, "2026-03-28", "2026-03-31", map[string]float64{"2026-03-29": 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.location.String()+" "+tt.first, func(t *testing.T) {
+			r := dayRange(analytics.GranularityDay, tt.location, tt.first, tt.last)
+
+			report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{Range: r})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Every day is present once, including the 23 hour one
+			wantDays := len(r.Periods())
+			if len(report.Series) != wantDays {
+				t.Fatalf("%d periods, want %d", len(report.Series), wantDays)
+			}
+
+			got := map[string]float64{}
+			for _, point := range report.Series {
+				if point.Revenue != 0 {
+					got[point.Period] = point.Revenue
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("revenue by day %v, want %v", got, tt.want)
+			}
+
+			if report.Timezone != tt.location.String() {
+				t.Errorf("timezone %s, want %s", report.Timezone, tt.location)
+			}
+		})
+	}
+}
+
+func TestSalesBreakdownByProduct(t *testing.T) {
+	db := testutil.DB(t)
+
+	createOrder(t, db, at(t, "2026-03-01T12:00:00Z"), models.OrderStatusPaid, 1, item(1, "Mug", 10, 1), item(2, "Shirt", 5, 1))
+	// Product 1 was renamed, product 3 took its old title
+	createOrder(t, db, at(t, "2026-03-02T12:00:00Z"), models.OrderStatusPaid, 1, item(1, "Coffee Mug", 10, 2), item(3, "Mug", 4, 1))
+	// Items recorded before product IDs were stored are grouped by title
+	createOrder(t, db, at(t, "2026-03-02T13:00:00Z"), models.OrderStatusPaid, 1, item(0, "Hat", 3, 1))
+
+	report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{
+		Range:     dayRange(analytics.GranularityDay, time.UTC, "2026-03-01", "2026-03-02"),
+		Breakdown: analytics.BreakdownProduct,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type group struct {
+		Key     string
+		Label   string
+		Revenue float64
+		Orders  int64
+	}
+
+	var got []group
+	for _, g := range report.Breakdown {
+		got = append(got, group{g.Key, g.Label, g.Totals.Revenue, g.Totals.Orders})
+
+		if len(g.Series) != 2 {
+			t.Errorf("group %s: %d periods, want 2", g.Key, len(g.Series))
+		}
+	}
+
+	want := []group{
+		{Key: "1", Label: "Coffee Mug", Revenue: 30, Orders: 2},
+		{Key: "2", Label: "Shirt", Revenue: 5, Orders: 1},
+		{Key: "3", Label: "Mug", Revenue: 4, Orders: 1},
+		{Key: "title:Hat", Label: "Hat", Revenue: 3, Orders: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("breakdown %+v, want %+v", got, want)
+	}
+}
+
+func TestSalesBreakdownByAmbassador(t *testing.T) {
+	db := testutil.DB(t)
+
+	createOrder(t, db, at(t, "2026-03-01T12:00:00Z"), models.OrderStatusPaid, 7, item(1, "Mug", 10, 1))
+	createOrder(t, db, at(t, "2026-03-01T13:00:00Z"), models.OrderStatusPaid, 8, item(1, "Mug", 10, 3))
+
+	report, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{
+		Range:     dayRange(analytics.GranularityDay, time.UTC, "2026-03-01", "2026-03-01"),
+		Breakdown: analytics.BreakdownAmbassador,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Breakdown) != 2 || report.Breakdown[0].Key != "8" || report.Breakdown[1].Key != "7" {
+		t.Fatalf("breakdown %+v, want ambassadors 8 and 7 by revenue", report.Breakdown)
+	}
+	if report.Breakdown[0].Label != "ambassador@example.com" || report.Breakdown[0].Totals.Revenue != 30 {
+		t.Errorf("group %+v", report.Breakdown[0])
+	}
+}
+
+func TestSalesRejectsTooManyPeriods(t *testing.T) {
+	db := testutil.DB(t)
+
+	r := dayRange(analytics.GranularityDay, time.UTC, "2020-01-01", "2026-01-01")
+	if _, err := analytics.Sales(context.Background(), db, analytics.SalesOptions{Range: r}); err == nil {
+		t.Error("expected an error for a range over MaxPeriods days")
+	}
+}
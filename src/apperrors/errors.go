@@ -0,0 +1,102 @@
+package apperrors
+
+import (
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Error is an error that maps to an HTTP response
+// Handlers return it and the central error handler renders it as the JSON envelope
+type Error struct {
+	// Code is the HTTP status code
+	Code int
+	// Message is a short, client safe description
+	Message string
+	// Details carries optional structured data, e.g. field errors
+	Details interface{}
+	// Err is the underlying cause; it is logged but never sent to the client
+	Err error
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause so errors.Is/As see through the Error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns a copy of the error carrying the given details
+func (e *Error) WithDetails(details interface{}) *Error {
+	clone := *e
+	clone.Details = details
+	return &clone
+}
+
+// New creates an Error with the given HTTP status code and message
+func New(code int, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// BadRequest is returned for malformed requests (400)
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, message)
+}
+
+// Unauthorized is returned when the caller is not authenticated (401)
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, message)
+}
+
+// Forbidden is returned when the caller may not perform the action (403)
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, message)
+}
+
+// NotFound is returned when the requested resource does not exist (404)
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, message)
+}
+
+// Conflict is returned when the request clashes with the current state (409)
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, message)
+}
+
+// Validation is returned when the request body breaks validation rules (422)
+// details usually lists the failing fields
+func Validation(details interface{}) *Error {
+	return &Error{Code: http.StatusUnprocessableEntity, Message: "validation failed", Details: details}
+}
+
+// TooManyRequests is returned when the caller is rate limited (429)
+func TooManyRequests(message string) *Error {
+	return New(http.StatusTooManyRequests, message)
+}
+
+// Internal wraps an unexpected error (500); the cause is not exposed to the client
+func Internal(err error) *Error {
+	return &Error{Code: http.StatusInternalServerError, Message: "internal server error", Err: err}
+}
+
+// FromDB converts a database error into an Error
+// A missing row becomes a 404 naming the resource, a unique key violation a 409;
+// nil stays nil and anything else is an internal error
+func FromDB(err error, resource string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return &Error{Code: http.StatusNotFound, Message: resource + " not found", Err: err}
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return &Error{Code: http.StatusConflict, Message: resource + " already exists", Err: err}
+	}
+	return Internal(err)
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-ambassador/src/config"
+	"log"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMiss is returned by Store.Get when the key is not cached
+var ErrMiss = errors.New("cache: miss")
+
+// Store is implemented by every cache backend
+// Values are opaque bytes; tags group keys so they can be invalidated together
+type Store interface {
+	// Get returns the value stored under key or ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl and adds the key to every tag
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error
+	// Delete removes the keys
+	Delete(ctx context.Context, keys ...string) error
+	// Invalidate removes every key added to any of the tags
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+// Default is the store used by the typed helpers
+var Default Store
+
+// group collapses concurrent loads of the same key into a single call
+var group singleflight.Group
+
+// Setup selects the store described by cfg
+// The Redis store shares client with the rest of the application
+func Setup(cfg config.CacheConfig, client *redis.Client) {
+	switch cfg.Driver {
+	case "memory":
+		Default = NewMemory()
+	default:
+		Default = NewRedis(client)
+	}
+}
+
+// Get returns the JSON decoded value stored under key
+// The boolean is false on a miss
+func Get[T any](ctx context.Context, key string) (T, bool, error) {
+	var value T
+
+	if Default == nil {
+		return value, false, nil
+	}
+
+	raw, err := Default.Get(ctx, key)
+	if errors.Is(err, ErrMiss) {
+		return value, false, nil
+	}
+	if err != nil {
+		return value, false, err
+	}
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return value, false, err
+	}
+
+	return value, true, nil
+}
+
+// Set stores the JSON encoded value under key for ttl, tagged with tags
+func Set[T any](ctx context.Context, key string, value T, ttl time.Duration, tags ...string) error {
+	if Default == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	return Default.Set(ctx, key, raw, ttl, tags)
+}
+
+// Remember returns the value cached under key, calling load and caching its
+// result on a miss
+// Concurrent misses on the same key share a single call to load, so an
+// expired entry does not send every request to the database at once
+// Cache failures are logged and fall back to load; only load errors are returned
+func Remember[T any](ctx context.Context, key string, ttl time.Duration, tags []string, load func(ctx context.Context) (T, error)) (T, error) {
+	value, ok, err := Get[T](ctx, key)
+	if err != nil {
+		log.Printf("cache: reading %s: %v", key, err)
+	}
+	if ok {
+		return value, nil
+	}
+
+	// The shared load must not fail for everyone when the first caller goes away
+	loadCtx := context.WithoutCancel(ctx)
+
+	shared, err, _ := group.Do(key, func() (interface{}, error) {
+		value, err := load(loadCtx)
+		if err != nil {
+			return value, err
+		}
+
+		if err := Set(loadCtx, key, value, ttl, tags...); err != nil {
+			log.Printf("cache: writing %s: %v", key, err)
+		}
+
+		return value, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	return shared.(T), nil
+}
+
+// Forget removes the keys from the Default store
+func Forget(ctx context.Context, keys ...string) error {
+	if Default == nil {
+		return nil
+	}
+
+	return Default.Delete(ctx, keys...)
+}
+
+// Invalidate removes every key tagged with any of the tags from the Default store
+// It is a no-op when no store is set up, e.g. in the CLI commands
+func Invalidate(ctx context.Context, tags ...string) error {
+	if Default == nil {
+		return nil
+	}
+
+	return Default.Invalidate(ctx, tags...)
+}
@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/testutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRememberLoadsOnceForConcurrentMisses(t *testing.T) {
+	server := testutil.Redis(t)
+
+	const callers = 20
+	var loads atomic.Int32
+	var started sync.WaitGroup
+	started.Add(callers)
+
+	// The load holds until every caller has missed, so they all wait on it
+	load := func(ctx context.Context) ([]string, error) {
+		loads.Add(1)
+		started.Wait()
+		time.Sleep(50 * time.Millisecond)
+		return []string{"Mug"}, nil
+	}
+
+	var done sync.WaitGroup
+	results := make([][]string, callers)
+	for i := range callers {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			started.Done()
+
+			value, err := cache.Remember(context.Background(), "catalogue", time.Minute, []string{"products"}, load)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = value
+		}()
+	}
+	done.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times, want once", n)
+	}
+	for i, value := range results {
+		if len(value) != 1 || value[0] != "Mug" {
+			t.Errorf("caller %d got %q", i, value)
+		}
+	}
+	if !server.Exists("catalogue") {
+		t.Error("loaded value not cached")
+	}
+
+	// Later calls are served from the cache
+	if _, err := cache.Remember(context.Background(), "catalogue", time.Minute, nil, load); err != nil {
+		t.Fatal(err)
+	}
+	if n := loads.Load(); n != 1 {
+		t.Errorf("loaded %d times after a hit, want once", n)
+	}
+}
+
+func TestRememberDoesNotCacheErrors(t *testing.T) {
+	server := testutil.Redis(t)
+	failure := errors.New("database down")
+
+	_, err := cache.Remember(context.Background(), "catalogue", time.Minute, nil, func(ctx context.Context) (int, error) {
+		return 0, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("error %v, want %v", err, failure)
+	}
+	if server.Exists("catalogue") {
+		t.Error("failed load cached")
+	}
+
+	value, err := cache.Remember(context.Background(), "catalogue", time.Minute, nil, func(ctx context.Context) (int, error) {
+		return 7, nil
+	})
+	if err != nil || value != 7 {
+		t.Errorf("retry: %d, %v, want 7", value, err)
+	}
+}
+
+func TestInvalidateDropsTaggedKeys(t *testing.T) {
+	server := testutil.Redis(t)
+	ctx := context.Background()
+
+	for key, tag := range map[string]string{"catalogue": "products", "page:1": "products", "ranking": "rankings"} {
+		if err := cache.Set(ctx, key, 1, time.Minute, tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cache.Invalidate(ctx, "products"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]bool{"catalogue": false, "page:1": false, "ranking": true} {
+		if server.Exists(key) != want {
+			t.Errorf("%s cached: %v, want %v", key, !want, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPrefix namespaces the Redis sets that list the keys of each tag
+const tagPrefix = "cache:tag:"
+
+// Redis stores entries as plain Redis strings and tags as Redis sets
+// Tag sets have no expiry; members whose entry already expired are simply
+// deleted again on the next invalidation
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis creates a store backed by client
+func NewRedis(client *redis.Client) *Redis {
+	return &Redis{client: client}
+}
+
+// Get returns the value stored under key or ErrMiss
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+
+	return value, err
+}
+
+// Set stores the value and records the key under every tag in one round trip
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, value, ttl)
+		for _, tag := range tags {
+			pipe.SAdd(ctx, tagPrefix+tag, key)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Delete removes the keys
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// Invalidate deletes the keys listed in each tag set together with the set
+func (r *Redis) Invalidate(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		tagKey := tagPrefix + tag
+
+		keys, err := r.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+
+		if err := r.client.Del(ctx, append(keys, tagKey)...).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
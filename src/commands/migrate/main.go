@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"log"
+	"os"
+	"strconv"
+)
+
+// usage describes the available subcommands
+const usage = `Usage: go run ./src/commands/migrate [-dir path] <command>
+
+Commands:
+  up           apply all pending migrations (runs AutoMigrate first and
+               creates the ADMIN_EMAIL account when there is no admin yet)
+  down [N]     roll back the last N applied migrations (default 1)
+  status       list migrations and when they were applied
+  create NAME  write an empty up/down pair with the next version into -dir
+`
+
+// migrate applies the versioned SQL migrations embedded in this binary
+// It connects with the same configuration as the API
+func main() {
+	dir := flag.String("dir", "src/commands/migrate/migrations", "directory new migrations are written to by create")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// create only touches the filesystem and needs no database
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatal("create requires exactly one NAME")
+		}
+
+		paths, err := createMigration(*dir, args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	migrator, err := NewMigrator(database.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch args[0] {
+	case "up":
+		// Make sure the model tables exist before SQL migrations alter them
+		if err := database.AutoMigrate(); err != nil {
+			log.Fatal(err)
+		}
+
+		if err := database.SeedPermissions(); err != nil {
+			log.Fatal(err)
+		}
+
+		count, err := migrator.Up()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", count)
+
+		// Run after the migrations so promoted legacy admins count as existing
+		if err := database.SeedAdmin(cfg.Admin); err != nil {
+			log.Fatal(err)
+		}
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("down expects a positive number, got %q", args[1])
+			}
+		}
+
+		count, err := migrator.Down(n)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("rolled back %d migration(s)\n", count)
+
+	case "status":
+		if err := migrator.Status(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
@@ -0,0 +1 @@
+DROP INDEX idx_orders_code ON orders;
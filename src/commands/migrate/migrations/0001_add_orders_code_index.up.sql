@@ -0,0 +1,2 @@
+-- Orders are looked up by link code when computing ambassador statistics
+CREATE INDEX idx_orders_code ON orders (code(32));
@@ -0,0 +1,6 @@
+-- Only clears the timestamps set by up; accounts that verified a new address
+-- through a link since keep theirs
+UPDATE users SET email_verified_at = NULL
+WHERE id IN (SELECT user_id FROM migration_0002_verified_users WHERE verified_at = users.email_verified_at);
+
+DROP TABLE migration_0002_verified_users;
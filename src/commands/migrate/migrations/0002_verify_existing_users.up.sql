@@ -0,0 +1,12 @@
+-- Accounts created before email verification existed are treated as verified
+-- They are the rows without created_at, which shipped in the same release as
+-- email_verified_at; later sign-ups keep going through verification
+-- The verified users and the timestamp they got are recorded for down
+CREATE TABLE migration_0002_verified_users (user_id BIGINT NOT NULL PRIMARY KEY, verified_at DATETIME NOT NULL);
+
+INSERT INTO migration_0002_verified_users (user_id, verified_at)
+SELECT id, COALESCE(created_at, CURRENT_TIMESTAMP) FROM users
+WHERE email_verified_at IS NULL AND created_at IS NULL;
+
+UPDATE users SET email_verified_at = (SELECT verified_at FROM migration_0002_verified_users WHERE user_id = users.id)
+WHERE id IN (SELECT user_id FROM migration_0002_verified_users);
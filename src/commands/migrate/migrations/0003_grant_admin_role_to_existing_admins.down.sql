@@ -0,0 +1,7 @@
+-- Restores the previous role of the accounts up promoted, unless an admin has
+-- given them another role since
+UPDATE users SET role_id = (SELECT role_id FROM migration_0003_promoted_admins WHERE user_id = users.id)
+WHERE id IN (SELECT user_id FROM migration_0003_promoted_admins)
+AND role_id = (SELECT id FROM roles WHERE name = 'Admin');
+
+DROP TABLE migration_0003_promoted_admins;
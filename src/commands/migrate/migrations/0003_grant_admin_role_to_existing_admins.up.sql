@@ -0,0 +1,14 @@
+-- Admin accounts created before roles existed have no role and lost access to
+-- every admin resource; they get the Admin role back
+-- Like 0002 only rows without created_at are touched, so accounts registered
+-- since keep waiting for an admin to assign them a role
+-- The promoted users and their previous role_id are recorded for down
+CREATE TABLE migration_0003_promoted_admins (user_id BIGINT NOT NULL PRIMARY KEY, role_id BIGINT NULL);
+
+INSERT INTO migration_0003_promoted_admins (user_id, role_id)
+SELECT id, role_id FROM users
+WHERE is_ambassador = FALSE AND (role_id IS NULL OR role_id = 0) AND created_at IS NULL
+AND EXISTS (SELECT 1 FROM roles WHERE name = 'Admin');
+
+UPDATE users SET role_id = (SELECT id FROM roles WHERE name = 'Admin')
+WHERE id IN (SELECT user_id FROM migration_0003_promoted_admins);
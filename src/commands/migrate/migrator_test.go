@@ -0,0 +1,164 @@
+package main
+
+import (
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrate runs one direction of the embedded migration with the given version
+// The whole set cannot run on SQLite because 0001 uses a MySQL prefix index,
+// so each test applies the migration it covers on its own
+func migrate(t *testing.T, db *gorm.DB, version int64, up bool) {
+	t.Helper()
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, migration := range migrator.migrations {
+		if migration.Version != version {
+			continue
+		}
+
+		script := migration.Down
+		if up {
+			script = migration.Up
+		}
+
+		if err := migrator.run(migration, script, up); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	t.Fatalf("no migration %04d", version)
+}
+
+// legacyUser creates a user and clears created_at, like an account that
+// existed before the column was added
+func legacyUser(t *testing.T, db *gorm.DB, user models.User) models.User {
+	t.Helper()
+
+	user = testutil.CreateUser(t, user)
+	if err := db.Exec("UPDATE users SET created_at = NULL WHERE id = ?", user.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// verifiedAt reloads the email_verified_at of the user
+func verifiedAt(t *testing.T, db *gorm.DB, user models.User) *time.Time {
+	t.Helper()
+
+	var reloaded models.User
+	if err := db.First(&reloaded, user.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return reloaded.EmailVerifiedAt
+}
+
+func TestVerifyExistingUsers(t *testing.T) {
+	db := testutil.DB(t)
+
+	earlier := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	later := time.Date(2027, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	legacy := legacyUser(t, db, models.User{IsAmbassador: true})
+	legacyVerified := legacyUser(t, db, models.User{IsAmbassador: true, EmailVerifiedAt: &earlier})
+	reverified := legacyUser(t, db, models.User{IsAmbassador: true})
+	registered := testutil.CreateUser(t, models.User{IsAmbassador: true})
+
+	migrate(t, db, 2, true)
+
+	for name, user := range map[string]models.User{"legacy": legacy, "reverified": reverified} {
+		if verifiedAt(t, db, user) == nil {
+			t.Errorf("up: %s user not verified", name)
+		}
+	}
+	if at := verifiedAt(t, db, legacyVerified); at == nil || !at.Equal(earlier) {
+		t.Errorf("up: verified user has %v, want %v", at, earlier)
+	}
+	if at := verifiedAt(t, db, registered); at != nil {
+		t.Errorf("up: user registered since verified at %v", at)
+	}
+
+	// Verifying a changed address after the migration is kept by down
+	if err := db.Model(&reverified).Update("email_verified_at", later).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrate(t, db, 2, false)
+
+	if at := verifiedAt(t, db, legacy); at != nil {
+		t.Errorf("down: legacy user still verified at %v", at)
+	}
+	if at := verifiedAt(t, db, reverified); at == nil || !at.Equal(later) {
+		t.Errorf("down: reverified user has %v, want %v", at, later)
+	}
+	if at := verifiedAt(t, db, legacyVerified); at == nil || !at.Equal(earlier) {
+		t.Errorf("down: verified user has %v, want %v", at, earlier)
+	}
+
+	if db.Migrator().HasTable("migration_0002_verified_users") {
+		t.Error("down kept the tracking table")
+	}
+}
+
+// checkRole fails the test when the user's role_id is not want
+func checkRole(t *testing.T, db *gorm.DB, name string, user models.User, want uint) {
+	t.Helper()
+
+	var reloaded models.User
+	if err := db.First(&reloaded, user.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if reloaded.RoleId != want {
+		t.Errorf("%s has role %d, want %d", name, reloaded.RoleId, want)
+	}
+}
+
+func TestGrantAdminRoleToExistingAdmins(t *testing.T) {
+	db := testutil.DB(t)
+
+	var admin models.Role
+	if err := db.Where("name = ?", "Admin").First(&admin).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	legacyAdmin := legacyUser(t, db, models.User{})
+	legacyAmbassador := legacyUser(t, db, models.User{IsAmbassador: true})
+	legacyEditor := legacyUser(t, db, models.User{RoleId: 2})
+	registered := testutil.CreateUser(t, models.User{})
+	reassigned := legacyUser(t, db, models.User{})
+
+	migrate(t, db, 3, true)
+
+	checkRole(t, db, "up: legacy admin", legacyAdmin, admin.Id)
+	checkRole(t, db, "up: legacy ambassador", legacyAmbassador, 0)
+	checkRole(t, db, "up: legacy editor", legacyEditor, 2)
+	checkRole(t, db, "up: user registered since", registered, 0)
+	checkRole(t, db, "up: reassigned admin", reassigned, admin.Id)
+
+	// A role assigned after the migration survives the rollback
+	if err := db.Model(&reassigned).Update("role_id", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	migrate(t, db, 3, false)
+
+	checkRole(t, db, "down: legacy admin", legacyAdmin, 0)
+	checkRole(t, db, "down: legacy editor", legacyEditor, 2)
+	checkRole(t, db, "down: reassigned admin", reassigned, 3)
+
+	if db.Migrator().HasTable("migration_0003_promoted_admins") {
+		t.Error("down kept the tracking table")
+	}
+}
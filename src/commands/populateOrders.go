@@ -1,14 +1,24 @@
 package main
 
 import (
+	"go-ambassador/src/config"
 	"go-ambassador/src/database"
 	"go-ambassador/src/models"
+	"log"
 
 	"github.com/bxcodec/faker/v4"
 )
 
 func main() {
-	database.Connect()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
 
 	for i := 0; i < 30; i++ {
 		ambassador := models.User{
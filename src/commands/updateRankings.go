@@ -1 +1,32 @@
 package main
+
+import (
+	"context"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"log"
+)
+
+// updateRankings rebuilds the Redis rankings sorted set from MySQL
+// Run it to repair drift between the incremental updates and the orders table
+// Usage: go run src/commands/updateRankings.go
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := database.Connect(cfg.Database); err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+	database.SetupRedis(cfg.Redis)
+	cache.Setup(cfg.Cache, database.Cache)
+
+	if err := database.RebuildRankings(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Println("rankings rebuilt")
+}
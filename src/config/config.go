@@ -0,0 +1,510 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Config holds all runtime settings of the API and the commands
+type Config struct {
+	ListenAddr string         `json:"listen_addr"`
+	AppURL     string         `json:"app_url"`
+	Database   DatabaseConfig `json:"database"`
+	Redis      RedisConfig    `json:"redis"`
+	Cache      CacheConfig    `json:"cache"`
+	JWT        JWTConfig      `json:"jwt"`
+	Cookie     CookieConfig   `json:"cookie"`
+	Payments   PaymentsConfig `json:"payments"`
+	Mail       MailConfig     `json:"mail"`
+	Storage    StorageConfig  `json:"storage"`
+	Exports    ExportsConfig  `json:"exports"`
+	Admin      AdminConfig    `json:"admin"`
+}
+
+// DatabaseConfig configures the MySQL connection and its pool
+type DatabaseConfig struct {
+	DSN             string   `json:"dsn"`
+	MaxOpenConns    int      `json:"max_open_conns"`
+	MaxIdleConns    int      `json:"max_idle_conns"`
+	ConnMaxLifetime Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime Duration `json:"conn_max_idle_time"`
+
+	// ConnectAttempts and ConnectBackoff control retries while MySQL is starting
+	// The backoff doubles after every failed attempt
+	ConnectAttempts int      `json:"connect_attempts"`
+	ConnectBackoff  Duration `json:"connect_backoff"`
+}
+
+// RedisConfig configures the Redis client
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+// CacheConfig configures the query cache
+// Driver is "redis" to share cached data between instances or "memory" to
+// keep it in process
+type CacheConfig struct {
+	Driver string `json:"driver"`
+}
+
+// JWTConfig configures token signing and session lifetimes
+// Access tokens are short-lived; refresh tokens rotate on every use
+type JWTConfig struct {
+	Secret          string   `json:"secret"`
+	AccessTokenTTL  Duration `json:"access_token_ttl"`
+	RefreshTokenTTL Duration `json:"refresh_token_ttl"`
+}
+
+// CookieConfig configures the authentication cookie
+type CookieConfig struct {
+	Domain   string `json:"domain"`
+	Secure   bool   `json:"secure"`
+	SameSite string `json:"same_site"`
+}
+
+// PaymentsConfig configures the payment provider
+// Driver must be set explicitly: "stripe" charges buyers through the Stripe
+// API and requires StripeSecretKey, "fake" marks orders paid without charging
+// anyone and is only meant for tests and local development
+type PaymentsConfig struct {
+	Driver          string `json:"driver"`
+	StripeSecretKey string `json:"stripe_secret_key"`
+	StripeBaseURL   string `json:"stripe_base_url"`
+	CheckoutURL     string `json:"checkout_url"`
+}
+
+// MailConfig configures outgoing email
+// Driver is "smtp" for real delivery, "file" to write messages to FileDir
+// or "memory" to keep them in process
+type MailConfig struct {
+	Driver       string `json:"driver"`
+	From         string `json:"from"`
+	SMTPHost     string `json:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port"`
+	SMTPUsername string `json:"smtp_username"`
+	SMTPPassword string `json:"smtp_password"`
+	FileDir      string `json:"file_dir"`
+}
+
+// StorageConfig configures where generated files such as exports are kept
+// Driver "local" stores them below Dir
+type StorageConfig struct {
+	Driver string `json:"driver"`
+	Dir    string `json:"dir"`
+}
+
+// ExportsConfig configures background export jobs
+// Workers is the number of jobs generated concurrently by each instance;
+// DownloadTTL is how long a signed download link stays valid
+type ExportsConfig struct {
+	Workers     int      `json:"workers"`
+	DownloadTTL Duration `json:"download_ttl"`
+}
+
+// AdminConfig describes the first admin account
+// When Email is set and no user holds the Admin role yet, the account is
+// created with the Admin role on start; once an admin exists it is ignored
+type AdminConfig struct {
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// minSecretLength is the shortest JWT secret accepted at startup
+const minSecretLength = 16
+
+// minAdminPasswordLength and maxAdminPasswordLength bound ADMIN_PASSWORD
+// bcrypt ignores everything after 72 bytes
+const (
+	minAdminPasswordLength = 8
+	maxAdminPasswordLength = 72
+)
+
+// Default returns the configuration used when nothing else is set
+// It matches the services defined in docker-compose.yaml
+// JWT.Secret and Payments.Driver have no default and must always be provided
+func Default() Config {
+	return Config{
+		ListenAddr: ":8000",
+		AppURL:     "http://localhost:3000",
+		Database: DatabaseConfig{
+			DSN:             "root:root@tcp(db:3306)/ambassador?charset=utf8mb4&parseTime=True&loc=Local",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: Duration(30 * time.Minute),
+			ConnMaxIdleTime: Duration(5 * time.Minute),
+			ConnectAttempts: 10,
+			ConnectBackoff:  Duration(time.Second),
+		},
+		Redis: RedisConfig{
+			Addr: "redis:6379",
+		},
+		Cache: CacheConfig{
+			Driver: "redis",
+		},
+		JWT: JWTConfig{
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(7 * 24 * time.Hour),
+		},
+		Cookie: CookieConfig{
+			SameSite: "lax",
+		},
+		Payments: PaymentsConfig{
+			CheckoutURL: "http://localhost:5000",
+		},
+		Mail: MailConfig{
+			Driver:   "file",
+			From:     "no-reply@ambassador.local",
+			SMTPPort: 587,
+			FileDir:  "storage/mail",
+		},
+		Storage: StorageConfig{
+			Driver: "local",
+			Dir:    "storage/files",
+		},
+		Exports: ExportsConfig{
+			Workers:     2,
+			DownloadTTL: Duration(time.Hour),
+		},
+		Admin: AdminConfig{
+			FirstName: "Admin",
+			LastName:  "User",
+		},
+	}
+}
+
+// Load builds the configuration from defaults, an optional JSON file and the environment
+// The file is read from CONFIG_FILE when set; environment variables take precedence over it
+// The result is validated before it is returned
+func Load() (*Config, error) {
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loadEnv(&cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// loadFile overlays the JSON file at path onto cfg
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: reading %s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("config: parsing %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv overlays environment variables onto cfg
+func loadEnv(cfg *Config) error {
+	setString(&cfg.ListenAddr, "LISTEN_ADDR")
+	setString(&cfg.AppURL, "APP_URL")
+	setString(&cfg.Database.DSN, "DB_DSN")
+	setString(&cfg.Redis.Addr, "REDIS_ADDR")
+	setString(&cfg.Redis.Password, "REDIS_PASSWORD")
+	setString(&cfg.Cache.Driver, "CACHE_DRIVER")
+	setString(&cfg.JWT.Secret, "JWT_SECRET")
+	setString(&cfg.Cookie.Domain, "COOKIE_DOMAIN")
+	setString(&cfg.Cookie.SameSite, "COOKIE_SAMESITE")
+	setString(&cfg.Payments.Driver, "PAYMENT_DRIVER")
+	setString(&cfg.Payments.StripeSecretKey, "STRIPE_SECRET_KEY")
+	setString(&cfg.Payments.StripeBaseURL, "STRIPE_BASE_URL")
+	setString(&cfg.Payments.CheckoutURL, "CHECKOUT_URL")
+	setString(&cfg.Mail.Driver, "MAIL_DRIVER")
+	setString(&cfg.Mail.From, "MAIL_FROM")
+	setString(&cfg.Mail.SMTPHost, "SMTP_HOST")
+	setString(&cfg.Mail.SMTPUsername, "SMTP_USERNAME")
+	setString(&cfg.Mail.SMTPPassword, "SMTP_PASSWORD")
+	setString(&cfg.Mail.FileDir, "MAIL_FILE_DIR")
+	setString(&cfg.Storage.Driver, "STORAGE_DRIVER")
+	setString(&cfg.Storage.Dir, "STORAGE_DIR")
+	setString(&cfg.Admin.Email, "ADMIN_EMAIL")
+	setString(&cfg.Admin.Password, "ADMIN_PASSWORD")
+	setString(&cfg.Admin.FirstName, "ADMIN_FIRST_NAME")
+	setString(&cfg.Admin.LastName, "ADMIN_LAST_NAME")
+
+	if err := setInt(&cfg.Exports.Workers, "EXPORT_WORKERS"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.Exports.DownloadTTL, "EXPORT_DOWNLOAD_TTL"); err != nil {
+		return err
+	}
+
+	if err := setInt(&cfg.Mail.SMTPPort, "SMTP_PORT"); err != nil {
+		return err
+	}
+
+	if err := setInt(&cfg.Redis.DB, "REDIS_DB"); err != nil {
+		return err
+	}
+
+	if err := setInt(&cfg.Database.MaxOpenConns, "DB_MAX_OPEN_CONNS"); err != nil {
+		return err
+	}
+
+	if err := setInt(&cfg.Database.MaxIdleConns, "DB_MAX_IDLE_CONNS"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.Database.ConnMaxLifetime, "DB_CONN_MAX_LIFETIME"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.Database.ConnMaxIdleTime, "DB_CONN_MAX_IDLE_TIME"); err != nil {
+		return err
+	}
+
+	if err := setInt(&cfg.Database.ConnectAttempts, "DB_CONNECT_ATTEMPTS"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.Database.ConnectBackoff, "DB_CONNECT_BACKOFF"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.JWT.AccessTokenTTL, "JWT_ACCESS_TTL"); err != nil {
+		return err
+	}
+
+	if err := setDuration(&cfg.JWT.RefreshTokenTTL, "JWT_REFRESH_TTL"); err != nil {
+		return err
+	}
+
+	return setBool(&cfg.Cookie.Secure, "COOKIE_SECURE")
+}
+
+// Validate checks every setting and reports all problems at once
+func (cfg *Config) Validate() error {
+	var problems []string
+
+	if _, _, err := net.SplitHostPort(cfg.ListenAddr); err != nil {
+		problems = append(problems, fmt.Sprintf("LISTEN_ADDR %q is not a valid host:port", cfg.ListenAddr))
+	}
+
+	if cfg.Database.DSN == "" {
+		problems = append(problems, "DB_DSN is required")
+	} else if dsn, err := mysql.ParseDSN(cfg.Database.DSN); err != nil {
+		problems = append(problems, fmt.Sprintf("DB_DSN is invalid: %v", err))
+	} else if !dsn.ParseTime {
+		problems = append(problems, "DB_DSN must set parseTime=True so timestamps can be scanned")
+	}
+
+	if cfg.Database.MaxOpenConns < 1 {
+		problems = append(problems, "DB_MAX_OPEN_CONNS must be at least 1")
+	}
+
+	if cfg.Database.MaxIdleConns < 0 || cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		problems = append(problems, "DB_MAX_IDLE_CONNS must be between 0 and DB_MAX_OPEN_CONNS")
+	}
+
+	if cfg.Database.ConnectAttempts < 1 {
+		problems = append(problems, "DB_CONNECT_ATTEMPTS must be at least 1")
+	}
+
+	if cfg.Redis.Addr == "" {
+		problems = append(problems, "REDIS_ADDR is required")
+	}
+
+	switch cfg.Cache.Driver {
+	case "redis", "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("CACHE_DRIVER %q must be one of redis, memory", cfg.Cache.Driver))
+	}
+
+	if cfg.JWT.Secret == "" {
+		problems = append(problems, "JWT_SECRET is required")
+	} else if len(cfg.JWT.Secret) < minSecretLength {
+		problems = append(problems, fmt.Sprintf("JWT_SECRET must be at least %d characters", minSecretLength))
+	}
+
+	if cfg.JWT.AccessTokenTTL <= 0 {
+		problems = append(problems, "JWT_ACCESS_TTL must be positive")
+	}
+
+	if cfg.JWT.RefreshTokenTTL <= cfg.JWT.AccessTokenTTL {
+		problems = append(problems, "JWT_REFRESH_TTL must be longer than JWT_ACCESS_TTL")
+	}
+
+	switch strings.ToLower(cfg.Cookie.SameSite) {
+	case "lax", "strict":
+	case "none":
+		if !cfg.Cookie.Secure {
+			problems = append(problems, "COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("COOKIE_SAMESITE %q must be one of lax, strict, none", cfg.Cookie.SameSite))
+	}
+
+	switch cfg.Payments.Driver {
+	case "stripe":
+		if cfg.Payments.StripeSecretKey == "" {
+			problems = append(problems, "PAYMENT_DRIVER=stripe requires STRIPE_SECRET_KEY")
+		}
+	case "fake":
+	case "":
+		problems = append(problems, "PAYMENT_DRIVER is required, use stripe or fake")
+	default:
+		problems = append(problems, fmt.Sprintf("PAYMENT_DRIVER %q must be one of stripe, fake", cfg.Payments.Driver))
+	}
+
+	if cfg.Payments.StripeBaseURL != "" {
+		if _, err := url.ParseRequestURI(cfg.Payments.StripeBaseURL); err != nil {
+			problems = append(problems, fmt.Sprintf("STRIPE_BASE_URL is invalid: %v", err))
+		}
+	}
+
+	if _, err := url.ParseRequestURI(cfg.Payments.CheckoutURL); err != nil {
+		problems = append(problems, fmt.Sprintf("CHECKOUT_URL is invalid: %v", err))
+	}
+
+	if _, err := url.ParseRequestURI(cfg.AppURL); err != nil {
+		problems = append(problems, fmt.Sprintf("APP_URL is invalid: %v", err))
+	}
+
+	if cfg.Mail.From == "" {
+		problems = append(problems, "MAIL_FROM is required")
+	}
+
+	switch cfg.Mail.Driver {
+	case "smtp":
+		if cfg.Mail.SMTPHost == "" || cfg.Mail.SMTPPort <= 0 {
+			problems = append(problems, "MAIL_DRIVER=smtp requires SMTP_HOST and SMTP_PORT")
+		}
+	case "file":
+		if cfg.Mail.FileDir == "" {
+			problems = append(problems, "MAIL_DRIVER=file requires MAIL_FILE_DIR")
+		}
+	case "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("MAIL_DRIVER %q must be one of smtp, file, memory", cfg.Mail.Driver))
+	}
+
+	switch cfg.Storage.Driver {
+	case "local":
+		if cfg.Storage.Dir == "" {
+			problems = append(problems, "STORAGE_DRIVER=local requires STORAGE_DIR")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("STORAGE_DRIVER %q must be local", cfg.Storage.Driver))
+	}
+
+	if cfg.Exports.Workers < 1 {
+		problems = append(problems, "EXPORT_WORKERS must be at least 1")
+	}
+
+	if cfg.Exports.DownloadTTL <= 0 {
+		problems = append(problems, "EXPORT_DOWNLOAD_TTL must be positive")
+	}
+
+	// The same limits as the register endpoint apply to the first admin
+	if cfg.Admin.Email != "" {
+		if _, err := mail.ParseAddress(cfg.Admin.Email); err != nil {
+			problems = append(problems, fmt.Sprintf("ADMIN_EMAIL %q is not a valid email address", cfg.Admin.Email))
+		}
+
+		if len(cfg.Admin.Password) < minAdminPasswordLength || len(cfg.Admin.Password) > maxAdminPasswordLength {
+			problems = append(problems, fmt.Sprintf("ADMIN_EMAIL requires ADMIN_PASSWORD of %d to %d characters", minAdminPasswordLength, maxAdminPasswordLength))
+		}
+
+		if cfg.Admin.FirstName == "" || cfg.Admin.LastName == "" {
+			problems = append(problems, "ADMIN_EMAIL requires ADMIN_FIRST_NAME and ADMIN_LAST_NAME")
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// setString copies the environment variable into target when it is set
+func setString(target *string, key string) {
+	if value, ok := os.LookupEnv(key); ok {
+		*target = value
+	}
+}
+
+// setInt parses the environment variable into target when it is set
+func setInt(target *int, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("config: %s must be an integer, got %q", key, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+// setBool parses the environment variable into target when it is set
+func setBool(target *bool, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("config: %s must be a boolean, got %q", key, value)
+	}
+
+	*target = parsed
+
+	return nil
+}
+
+// setDuration parses the environment variable into target when it is set
+func setDuration(target *Duration, key string) error {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("config: %s must be a duration such as 30s or 5m, got %q", key, value)
+	}
+
+	*target = Duration(parsed)
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RankingResponse is a single leaderboard row returned by Rankings
+type RankingResponse struct {
+	Id      uint    `json:"id"`
+	Name    string  `json:"name"`
+	Revenue float64 `json:"revenue"`
+}
+
+// rankingsCacheKey holds the cached leaderboard built by Rankings
+const rankingsCacheKey = "rankings:leaderboard"
+
+// rankingsCacheTTL bounds how stale the leaderboard can get if an invalidation is missed
+const rankingsCacheTTL = 5 * time.Minute
+
+// Rankings returns the ambassador leaderboard ordered by revenue
+// Scores come from the Redis rankings set; names are loaded from MySQL
+// The leaderboard is cached until a score or a user changes
+// URL: GET /api/ambassadors/rankings (also served at /api/ambassador/rankings)
+func Rankings(c fiber.Ctx) error {
+	tags := []string{models.RankingsCacheTag, models.UsersCacheTag}
+
+	response, err := cache.Remember(c.Context(), rankingsCacheKey, rankingsCacheTTL, tags, loadRankings)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response)
+}
+
+// loadRankings joins the Redis scores with the ambassador names
+func loadRankings(ctx context.Context) ([]RankingResponse, error) {
+	rankings, err := database.Rankings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the names of every ranked ambassador in one query
+	ids := make([]uint, 0, len(rankings))
+	for _, ranking := range rankings {
+		ids = append(ids, ranking.UserId)
+	}
+
+	var users []models.User
+	if len(ids) > 0 {
+		if err := database.DB.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.Id] = user.Name()
+	}
+
+	// Keep the Redis order, which is already sorted by revenue
+	response := make([]RankingResponse, 0, len(rankings))
+	for _, ranking := range rankings {
+		response = append(response, RankingResponse{
+			Id:      ranking.UserId,
+			Name:    names[ranking.UserId],
+			Revenue: ranking.Revenue,
+		})
+	}
+
+	return response, nil
+}
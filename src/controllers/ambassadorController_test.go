@@ -0,0 +1,105 @@
+package controllers_test
+
+import (
+	"fmt"
+	"go-ambassador/src/controllers"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// rankings fetches the leaderboard as client
+func rankings(t *testing.T, client *testutil.Client) []controllers.RankingResponse {
+	t.Helper()
+
+	res := client.Do(http.MethodGet, "/api/ambassadors/rankings", nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var rankings []controllers.RankingResponse
+	res.Decode(t, &rankings)
+
+	return rankings
+}
+
+func TestRankingsFollowPaidOrders(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug", "Shirt")
+
+	alice := verifiedAmbassador(t)
+	bob := verifiedAmbassador(t)
+	aliceClient := env.LoginAs(t, alice, util.ScopeAmbassador)
+	aliceLink := createLink(t, aliceClient, products...)
+	bobLink := createLink(t, env.LoginAs(t, bob, util.ScopeAmbassador), products...)
+
+	// Bob sells a mug (commission 1), Alice a mug and a shirt (commission 3)
+	for _, placed := range []checkoutResponse{
+		checkout(t, env, bobLink, products[0]),
+		checkout(t, env, aliceLink, products...),
+	} {
+		if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusOK {
+			t.Fatalf("confirming: status %d: %s", res.Status, res.Body)
+		}
+	}
+
+	// Unpaid orders do not count
+	checkout(t, env, bobLink, products...)
+
+	want := []controllers.RankingResponse{
+		{Id: alice.Id, Name: alice.Name(), Revenue: 3},
+		{Id: bob.Id, Name: bob.Name(), Revenue: 1},
+	}
+	if got := rankings(t, aliceClient); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rankings %+v, want %+v", got, want)
+	}
+
+	// The cached leaderboard is dropped when a score changes
+	placed := checkout(t, env, bobLink, products...)
+	confirm(t, env, placed.Order.TransactionId)
+
+	want = []controllers.RankingResponse{
+		{Id: bob.Id, Name: bob.Name(), Revenue: 4},
+		{Id: alice.Id, Name: alice.Name(), Revenue: 3},
+	}
+	if got := rankings(t, aliceClient); !reflect.DeepEqual(got, want) {
+		t.Errorf("after another sale: rankings %+v, want %+v", got, want)
+	}
+
+	// Refunding the sale removes its commission again
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	if res := admin.Do(http.MethodPost, fmt.Sprintf("/api/admin/orders/%d/refund", placed.Order.Id), map[string]float64{}); res.Status != http.StatusOK {
+		t.Fatalf("refunding: status %d: %s", res.Status, res.Body)
+	}
+
+	want = []controllers.RankingResponse{
+		{Id: alice.Id, Name: alice.Name(), Revenue: 3},
+		{Id: bob.Id, Name: bob.Name(), Revenue: 1},
+	}
+	if got := rankings(t, aliceClient); !reflect.DeepEqual(got, want) {
+		t.Errorf("after the refund: rankings %+v, want %+v", got, want)
+	}
+}
+
+func TestRankingsPaths(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	ambassador := env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+
+	for _, path := range []string{"/api/ambassadors/rankings", "/api/ambassador/rankings"} {
+		t.Run(path, func(t *testing.T) {
+			if res := ambassador.Do(http.MethodGet, path, nil); res.Status != http.StatusOK {
+				t.Errorf("ambassador: status %d, want 200: %s", res.Status, res.Body)
+			}
+			if res := env.Client(t).Do(http.MethodGet, path, nil); res.Status != http.StatusUnauthorized {
+				t.Errorf("anonymous: status %d, want 401", res.Status)
+			}
+			if res := admin.Do(http.MethodGet, path, nil); res.Status != http.StatusUnauthorized {
+				t.Errorf("admin token: status %d, want 401", res.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"go-ambassador/src/analytics"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// analyticsCacheTTL bounds how stale a report can get if an invalidation is missed
+const analyticsCacheTTL = 10 * time.Minute
+
+// defaultPeriods is the number of periods reported when no start date is given
+const defaultPeriods = 30
+
+// SalesAnalytics reports revenue, order count and average order value of paid
+// orders per day, week or month
+// Query parameters:
+//   - granularity: day (default), week or month
+//   - from, to: inclusive YYYY-MM-DD dates; to defaults to today and from to
+//     30 periods earlier
+//   - tz: IANA time zone the periods are aligned to, UTC by default
+//   - breakdown: product or ambassador adds one series per product or ambassador
+//
+// Periods without sales are included with zero values
+// URL: GET /api/admin/analytics/sales
+func SalesAnalytics(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	options, err := salesOptions(c)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("analytics:sales:%s:%s", options.Breakdown, options.Range.Key())
+
+	report, err := cache.Remember(c.Context(), key, analyticsCacheTTL, []string{models.OrdersCacheTag},
+		func(ctx context.Context) (*analytics.SalesReport, error) {
+			return analytics.Sales(ctx, database.DB, options)
+		})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(report)
+}
+
+// AmbassadorStats reports the caller's paid orders, revenue, commission and
+// conversion rate per link, with totals and a series of paid orders per period
+// Only links owned by the caller and orders placed through them are counted
+// Query parameters are granularity, from, to and tz, as for the sales report
+// URL: GET /api/ambassador/stats
+func AmbassadorStats(c fiber.Ctx) error {
+	// Identify the ambassador from the JWT cookie
+	id, _ := util.ParseJWT(c.Cookies("jwt"))
+	userId, _ := strconv.Atoi(id)
+
+	r, err := reportRange(c)
+	if err != nil {
+		return err
+	}
+
+	// The user tag drops the entry when the ambassador creates a link
+	key := fmt.Sprintf("stats:ambassador:%d:%s", userId, r.Key())
+	tags := []string{models.OrdersCacheTag, models.UserCacheTag(uint(userId))}
+
+	stats, err := cache.Remember(c.Context(), key, analyticsCacheTTL, tags,
+		func(ctx context.Context) (*analytics.AmbassadorStats, error) {
+			return analytics.Ambassador(ctx, database.DB, uint(userId), r)
+		})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(stats)
+}
+
+// salesOptions reads the report options from the query string
+// Returns a 400 error for unknown values, malformed dates or too many periods
+func salesOptions(c fiber.Ctx) (analytics.SalesOptions, error) {
+	options := analytics.SalesOptions{Breakdown: c.Query("breakdown")}
+
+	breakdowns := []string{analytics.BreakdownNone, analytics.BreakdownProduct, analytics.BreakdownAmbassador}
+	if !slices.Contains(breakdowns, options.Breakdown) {
+		return options, apperrors.BadRequest("breakdown must be one of product, ambassador")
+	}
+
+	var err error
+	options.Range, err = reportRange(c)
+
+	return options, err
+}
+
+// reportRange reads the granularity, from, to and tz query parameters
+// Returns a 400 error for unknown values, malformed dates or too many periods
+func reportRange(c fiber.Ctx) (analytics.Range, error) {
+	options := analytics.Range{Granularity: c.Query("granularity", analytics.GranularityDay)}
+
+	granularities := []string{analytics.GranularityDay, analytics.GranularityWeek, analytics.GranularityMonth}
+	if !slices.Contains(granularities, options.Granularity) {
+		return options, apperrors.BadRequest("granularity must be one of day, week, month")
+	}
+
+	location, err := time.LoadLocation(c.Query("tz", "UTC"))
+	if err != nil {
+		return options, apperrors.BadRequest("unknown time zone " + c.Query("tz"))
+	}
+	options.Location = location
+
+	// The range covers whole days in the chosen time zone; To is exclusive
+	now := time.Now().In(location)
+	last := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+	if raw := c.Query("to"); raw != "" {
+		if last, err = time.ParseInLocation(time.DateOnly, raw, location); err != nil {
+			return options, apperrors.BadRequest("invalid to: expected YYYY-MM-DD")
+		}
+	}
+	options.To = last.AddDate(0, 0, 1)
+
+	if raw := c.Query("from"); raw != "" {
+		if options.From, err = time.ParseInLocation(time.DateOnly, raw, location); err != nil {
+			return options, apperrors.BadRequest("invalid from: expected YYYY-MM-DD")
+		}
+	} else {
+		options.From = defaultFrom(last, options.Granularity)
+	}
+
+	if !options.From.Before(options.To) {
+		return options, apperrors.BadRequest("from must not be after to")
+	}
+
+	if len(options.Periods()) > analytics.MaxPeriods {
+		return options, apperrors.BadRequest(fmt.Sprintf("the range spans more than %d periods, use a coarser granularity", analytics.MaxPeriods))
+	}
+
+	return options, nil
+}
+
+// defaultFrom returns the start of the range ending on last that spans
+// defaultPeriods periods
+func defaultFrom(last time.Time, granularity string) time.Time {
+	switch granularity {
+	case analytics.GranularityWeek:
+		return last.AddDate(0, 0, -7*(defaultPeriods-1))
+	case analytics.GranularityMonth:
+		return last.AddDate(0, -(defaultPeriods - 1), 0)
+	default:
+		return last.AddDate(0, 0, -(defaultPeriods - 1))
+	}
+}
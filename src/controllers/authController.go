@@ -1,57 +1,124 @@
 package controllers
 
 import (
-	"golangProject/database"
-	"golangProject/models"
-	"golangProject/util"
+	"context"
+	"errors"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"log"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
-func Register(c fiber.Ctx) error {
-	// Create map to store request data
-	var data map[string]string
+// RegisterRequest is the body accepted by AdminRegister and AmbassadorRegister
+type RegisterRequest struct {
+	FirstName       string `json:"first_name" validate:"required,max=255"`
+	LastName        string `json:"last_name" validate:"required,max=255"`
+	Email           string `json:"email" validate:"required,email,max=255"`
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+	PasswordConfirm string `json:"password_confirm" validate:"required,eqfield=Password"`
+}
+
+// LoginRequest is the body accepted by Login
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UpdateInfoRequest is the body accepted by UpdateInfo
+type UpdateInfoRequest struct {
+	FirstName string `json:"first_name" validate:"required,max=255"`
+	LastName  string `json:"last_name" validate:"required,max=255"`
+	Email     string `json:"email" validate:"required,email,max=255"`
+}
+
+// UpdatePasswordRequest is the body accepted by UpdatePassword
+type UpdatePasswordRequest struct {
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+	PasswordConfirm string `json:"password_confirm" validate:"required,eqfield=Password"`
+}
+
+// AdminRegister creates an admin account without a role
+// The account can sign in to the admin API but IsAuthorized denies it every
+// resource until an admin with edit_users assigns it a role
+// URL: POST /api/admin/register
+func AdminRegister(c fiber.Ctx) error {
+	return register(c, false)
+}
+
+// AmbassadorRegister creates an ambassador account
+// URL: POST /api/ambassador/register
+func AmbassadorRegister(c fiber.Ctx) error {
+	return register(c, true)
+}
+
+// register creates an account of the kind fixed by the route
+func register(c fiber.Ctx, isAmbassador bool) error {
+	var request RegisterRequest
 
-	// Parse request body into data map
-	if err := c.Bind().Body(&data); err != nil {
+	// Parse and validate the request body, including the password confirmation
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
-	// Check if password and password confirmation match
-	if data["password"] != data["password_confirm"] {
-		c.Status(400) // Set HTTP status to 400 Bad Request
-		return c.JSON(fiber.Map{
-			"code":    400,
-			"message": "Passwords do not match",
-		})
+	// Each email can only be registered once
+	if err := checkEmailAvailable(request.Email, 0); err != nil {
+		return err
 	}
 
 	// Create new User instance with data from request
+	// Self-registered accounts never get a role, only an admin can grant one
+	// New accounts start unverified until the emailed link is followed
 	user := models.User{
-		FirstName: data["first_name"],
-		LastName:  data["last_name"],
-		Email:     data["email"],
-		RoleId:    3,
+		FirstName:    request.FirstName,
+		LastName:     request.LastName,
+		Email:        request.Email,
+		IsAmbassador: isAmbassador,
 	}
 
 	// Generate a hashed password
-	user.SetPassword(data["password"])
+	user.SetPassword(request.Password)
 
 	// Save user to database
-	database.DB.Create(&user)
+	if err := database.DB.Create(&user).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	// Send the verification link; the user can ask for a new one if this fails
+	if err := sendVerificationEmail(c, user); err != nil {
+		log.Println("failed to send verification email:", err)
+	}
 
 	// Return the created user as JSON response
 	return c.JSON(user)
 }
 
-func Login(c fiber.Ctx) error {
-	// Create map to store request data
-	var data map[string]string
+// AdminLogin signs a user in to the admin API
+// URL: POST /api/admin/login
+func AdminLogin(c fiber.Ctx) error {
+	return login(c, util.ScopeAdmin)
+}
+
+// AmbassadorLogin signs a user in to the ambassador API
+// URL: POST /api/ambassador/login
+func AmbassadorLogin(c fiber.Ctx) error {
+	return login(c, util.ScopeAmbassador)
+}
+
+// login checks the credentials and starts a session whose tokens carry scope
+// The scope is fixed by the route rather than read from the request path,
+// which Fiber matches case-insensitively
+func login(c fiber.Ctx, scope string) error {
+	var request LoginRequest
 
-	// Parse request body into data map
-	if err := c.Bind().Body(&data); err != nil {
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
@@ -59,52 +126,43 @@ func Login(c fiber.Ctx) error {
 	var user models.User
 
 	// Find user by email in database
-	database.DB.Where("email = ?", data["email"]).First(&user)
-
-	// Check if user was found (ID 0 means not found)
-	if user.Id == 0 {
-		c.Status(404) // Set HTTP status to 404 Not Found
-		return c.JSON(fiber.Map{
-			"code":    404,
-			"message": "email not found",
-		})
+	err := database.DB.Where("email = ?", request.Email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.NotFound("email not found")
+	}
+	if err != nil {
+		return apperrors.Internal(err)
 	}
 
 	// Compare provided password with stored hashed password using model method
 	// This uses bcrypt.CompareHashAndPassword internally for secure comparison
-	if err := user.ComparePassword(data["password"]); err != nil {
-		c.Status(400) // Set HTTP status to 400 Bad Request
-		return c.JSON(fiber.Map{
-			"code":    400,
-			"message": "incorrect password",
-		})
+	if err := user.ComparePassword(request.Password); err != nil {
+		return apperrors.BadRequest("incorrect password")
 	}
 
-	// Generate JWT token using the utility function - convert user ID to string
-	token, err := util.GenerateJWT(strconv.Itoa(int(user.Id)))
-
-	// Check if token creation failed
-	if err != nil {
-		return c.SendStatus(fiber.StatusInternalServerError)
+	// Ambassadors may not sign in to the admin API
+	if scope == util.ScopeAdmin && user.IsAmbassador {
+		return apperrors.Unauthorized("unauthorized")
 	}
 
-	// Create HTTP-only cookie to store JWT
-	cookie := fiber.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Expires:  time.Now().Add(time.Hour * 24),
-		HTTPOnly: true,
+	// Start a server-side session and set the short-lived access token cookie
+	// together with the rotating refresh token cookie
+	if err := startSession(c, user.Id, scope); err != nil {
+		return err
 	}
 
-	// Set the cookie in response
-	c.Cookie(&cookie)
-
 	// Return success message
 	return c.JSON(fiber.Map{
 		"message": "success login",
 	})
 }
 
+// userCacheTTL bounds how stale a cached user can get if an invalidation is missed
+const userCacheTTL = 5 * time.Minute
+
+// User returns the authenticated user
+// The user is cached until it is written; the cached copy only holds the JSON
+// fields, so it must not be used to check passwords
 func User(c fiber.Ctx) error {
 	// Get JWT token from cookie
 	cookie := c.Cookies("jwt")
@@ -112,27 +170,34 @@ func User(c fiber.Ctx) error {
 	// Parse and validate JWT token, extract user ID
 	id, _ := util.ParseJWT(cookie)
 
-	// Create user variable to store query result
-	var user models.User
+	// Convert the user ID string to integer
+	userId, _ := strconv.Atoi(id)
 
 	// Find user by ID from JWT claims
-	database.DB.Where("id = ?", id).First(&user)
+	tags := []string{models.UserCacheTag(uint(userId)), models.UsersCacheTag}
+	user, err := cache.Remember(c.Context(), "user:"+id, userCacheTTL, tags, func(ctx context.Context) (models.User, error) {
+		var user models.User
+		err := database.DB.WithContext(ctx).Where("id = ?", userId).First(&user).Error
+		return user, err
+	})
+	if err != nil {
+		return apperrors.FromDB(err, "user")
+	}
 
 	// Return user information as JSON (excluding password due to json:"-" tag)
 	return c.JSON(user)
 }
 
 func Logout(c fiber.Ctx) error {
-	// Create a cookie with the same name as the JWT cookie but with empty value (remove cookie)
-	cookie := fiber.Cookie{
-		Name:     "jwt",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HTTPOnly: true,
+	// Revoke the session server-side so neither token can be used again
+	if claims, err := util.ParseClaims(c.Cookies("jwt")); err == nil {
+		if err := revokeSessionFamily(claims.SessionId); err != nil {
+			return err
+		}
 	}
 
-	// Set the cookie in the response - this will overwrite and clear the existing JWT cookie
-	c.Cookie(&cookie)
+	// Overwrite and clear the access and refresh token cookies
+	clearSessionCookies(c)
 
 	// Return success message confirming logout
 	return c.JSON(fiber.Map{
@@ -144,11 +209,10 @@ func Logout(c fiber.Ctx) error {
 // This includes first name, last name, and email address
 // Users can only update their own information, identified via JWT token
 func UpdateInfo(c fiber.Ctx) error {
-	// Create a map to store the updated user data from request body
-	var data map[string]string
+	var request UpdateInfoRequest
 
-	// Parse the JSON request body into the data map
-	if err := c.Bind().Body(&data); err != nil {
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
@@ -161,17 +225,43 @@ func UpdateInfo(c fiber.Ctx) error {
 	// Convert the user ID string to integer
 	userId, _ := strconv.Atoi(id)
 
+	var current models.User
+	if err := database.DB.Where("id = ?", userId).First(&current).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	// A changed email must not belong to another account
+	emailChanged := request.Email != current.Email
+	if emailChanged {
+		if err := checkEmailAvailable(request.Email, current.Id); err != nil {
+			return err
+		}
+	}
+
 	// Create a User instance with the authenticated user's ID and updated fields
 	user := models.User{
 		Id:        uint(userId),
-		FirstName: data["first_name"],
-		LastName:  data["last_name"],
-		Email:     data["email"],
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Email:     request.Email,
 	}
 
 	// Update the user record in the database
 	// This executes: UPDATE users SET first_name=?, last_name=?, email=? WHERE id=?;
-	database.DB.Model(&user).Updates(user)
+	if err := database.DB.Model(&user).Updates(user).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	// A new email address has to be verified again
+	if emailChanged {
+		if err := database.DB.Model(&user).Update("email_verified_at", nil).Error; err != nil {
+			return err
+		}
+
+		if err := sendVerificationEmail(c, user); err != nil {
+			log.Println("failed to send verification email:", err)
+		}
+	}
 
 	// Return the updated user as JSON response
 	return c.JSON(user)
@@ -181,23 +271,13 @@ func UpdateInfo(c fiber.Ctx) error {
 // Requires password confirmation to prevent typos
 // Users can only change their own password, identified via JWT token
 func UpdatePassword(c fiber.Ctx) error {
-	// Create a map to store password data from request body
-	var data map[string]string
+	var request UpdatePasswordRequest
 
-	// Parse the JSON request body into the data map
-	if err := c.Bind().Body(&data); err != nil {
+	// Parse and validate the request body, including the password confirmation
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
-	// Validate that password and password confirmation match
-	if data["password"] != data["password_confirm"] {
-		c.Status(400) // Set HTTP status to 400 Bad Request
-		return c.JSON(fiber.Map{
-			"code":    400,
-			"message": "Passwords do not match",
-		})
-	}
-
 	// Extract JWT token from the authentication cookie
 	cookie := c.Cookies("jwt")
 
@@ -213,11 +293,13 @@ func UpdatePassword(c fiber.Ctx) error {
 	}
 
 	// Hash the new password using the User model's SetPassword method
-	user.SetPassword(data["password"])
+	user.SetPassword(request.Password)
 
 	// Update only the password field in the database
 	// This executes: UPDATE users SET password=? WHERE id=?;
-	database.DB.Model(&user).Updates(user)
+	if err := database.DB.Model(&user).Updates(user).Error; err != nil {
+		return err
+	}
 
 	// Return the updated user as JSON response (password excluded due to json:"-")
 	return c.JSON(user)
@@ -0,0 +1,164 @@
+package controllers_test
+
+import (
+	"fmt"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterCreatesAmbassadorWithoutRole(t *testing.T) {
+	env := testutil.Setup(t)
+	client := env.Client(t)
+
+	res := client.Do(http.MethodPost, "/api/ambassador/register", map[string]string{
+		"first_name":       "New",
+		"last_name":        "Ambassador",
+		"email":            "new@example.com",
+		"password":         "password1",
+		"password_confirm": "password1",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var user models.User
+	if err := env.DB.Where("email = ?", "new@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !user.IsAmbassador || user.RoleId != 0 {
+		t.Errorf("user %+v: want an ambassador without a role", user)
+	}
+	if _, ok := env.Mail.Last("new@example.com"); !ok {
+		t.Error("no verification email sent")
+	}
+
+	// The account cannot reach the admin API
+	res = client.Do(http.MethodPost, "/api/admin/login", map[string]string{"email": "new@example.com", "password": "password1"})
+	if res.Status != http.StatusUnauthorized {
+		t.Errorf("admin login: status %d, want 401", res.Status)
+	}
+}
+
+func TestAdminRegisterCreatesUserWithoutRole(t *testing.T) {
+	env := testutil.Setup(t)
+	client := env.Client(t)
+
+	res := client.Do(http.MethodPost, "/api/admin/register", map[string]string{
+		"first_name":       "New",
+		"last_name":        "Admin",
+		"email":            "admin@example.com",
+		"password":         "password1",
+		"password_confirm": "password1",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var user models.User
+	if err := env.DB.Where("email = ?", "admin@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.IsAmbassador || user.RoleId != 0 {
+		t.Errorf("user %+v: want an admin without a role", user)
+	}
+
+	// The account can sign in but is denied every resource until it gets a role
+	res = client.Do(http.MethodPost, "/api/admin/login", map[string]string{"email": "admin@example.com", "password": "password1"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("login: status %d: %s", res.Status, res.Body)
+	}
+	if res := client.Do(http.MethodGet, "/api/admin/users", nil); res.Status != http.StatusForbidden {
+		t.Errorf("without a role: status %d, want 403", res.Status)
+	}
+
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	res = admin.Do(http.MethodPut, fmt.Sprintf("/api/admin/users/%d", user.Id), map[string]interface{}{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      user.Email,
+		"role_id":    3,
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("assigning a role: status %d: %s", res.Status, res.Body)
+	}
+
+	if res := client.Do(http.MethodGet, "/api/admin/users", nil); res.Status != http.StatusOK {
+		t.Errorf("as a viewer: status %d: %s", res.Status, res.Body)
+	}
+}
+
+func TestTokensOnlyWorkOnTheirRouteGroup(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	ambassador := env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+
+	tests := []struct {
+		name   string
+		client *testutil.Client
+		path   string
+		status int
+	}{
+		{"ambassador token on ambassador route", ambassador, "/api/ambassador/links", http.StatusOK},
+		{"ambassador token on mixed-case ambassador route", ambassador, "/api/Ambassador/links", http.StatusOK},
+		{"admin token on ambassador route", admin, "/api/ambassador/links", http.StatusUnauthorized},
+		{"admin token on mixed-case ambassador route", admin, "/api/Ambassador/links", http.StatusUnauthorized},
+		{"admin token on upper-case ambassador route", admin, "/API/AMBASSADOR/stats", http.StatusUnauthorized},
+		{"admin token on mixed-case rankings", admin, "/api/Ambassadors/rankings", http.StatusUnauthorized},
+		{"admin token on admin route", admin, "/api/admin/products", http.StatusOK},
+		{"admin token on mixed-case admin route", admin, "/API/Admin/products", http.StatusOK},
+		{"ambassador token on admin route", ambassador, "/api/admin/products", http.StatusUnauthorized},
+		{"ambassador token on mixed-case admin route", ambassador, "/API/ADMIN/products", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := tt.client.Do(http.MethodGet, tt.path, nil); res.Status != tt.status {
+				t.Errorf("status %d, want %d: %s", res.Status, tt.status, res.Body)
+			}
+		})
+	}
+}
+
+func TestLoginScopeFollowsRouteNotPathCase(t *testing.T) {
+	env := testutil.Setup(t)
+	adminUser := testutil.CreateUser(t, models.User{RoleId: 1})
+	ambassadorUser := verifiedAmbassador(t)
+
+	login := func(path string, user models.User) *testutil.Client {
+		client := env.Client(t)
+		res := client.Do(http.MethodPost, path, map[string]string{"email": user.Email, "password": testutil.Password})
+		if res.Status != http.StatusOK {
+			t.Fatalf("%s: status %d: %s", path, res.Status, res.Body)
+		}
+		return client
+	}
+
+	// Ambassadors cannot sign in to the admin API whatever the case of the path
+	for _, path := range []string{"/api/admin/login", "/api/Admin/login", "/API/ADMIN/LOGIN"} {
+		res := env.Client(t).Do(http.MethodPost, path, map[string]string{"email": ambassadorUser.Email, "password": testutil.Password})
+		if res.Status != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want 401", path, res.Status)
+		}
+	}
+
+	// A login on a mixed-case ambassador path still mints an ambassador token
+	client := login("/API/Ambassador/login", adminUser)
+	if res := client.Do(http.MethodGet, "/api/admin/products", nil); res.Status != http.StatusUnauthorized {
+		t.Errorf("ambassador-scoped token on admin route: status %d, want 401", res.Status)
+	}
+	if res := client.Do(http.MethodGet, "/api/ambassador/user", nil); res.Status != http.StatusOK {
+		t.Errorf("ambassador-scoped token on ambassador route: status %d: %s", res.Status, res.Body)
+	}
+
+	// And a mixed-case admin login mints an admin token
+	client = login("/api/ADMIN/login", adminUser)
+	if res := client.Do(http.MethodGet, "/api/admin/products", nil); res.Status != http.StatusOK {
+		t.Errorf("admin token on admin route: status %d: %s", res.Status, res.Body)
+	}
+	if res := client.Do(http.MethodGet, "/api/ambassador/user", nil); res.Status != http.StatusUnauthorized {
+		t.Errorf("admin token on ambassador route: status %d, want 401", res.Status)
+	}
+}
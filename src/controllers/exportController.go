@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/export"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/storage"
+	"go-ambassador/src/util"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// ExportJobResponse is an export job with its progress and, once completed,
+// a signed download link
+type ExportJobResponse struct {
+	models.ExportJob
+	Progress    float64    `json:"progress"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateExportJob queues an order export that is generated in the background
+// Accepts the same query parameters as Export
+// URL: POST /api/admin/exports
+func CreateExportJob(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	options, err := exportOptions(c)
+	if err != nil {
+		return err
+	}
+
+	id, _ := util.ParseJWT(c.Cookies("jwt"))
+	userId, _ := strconv.Atoi(id)
+
+	job, err := export.Enqueue(c.Context(), uint(userId), options)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(newExportJobResponse(job))
+}
+
+// GetExportJob reports the progress of an export job
+// Completed jobs include a download link that expires after the configured TTL
+// URL: GET /api/admin/exports/:id
+func GetExportJob(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var job models.ExportJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		return apperrors.FromDB(err, "export")
+	}
+
+	return c.JSON(newExportJobResponse(&job))
+}
+
+// DownloadExport sends the file of a completed export job
+// The route is not authenticated; the signature issued by GetExportJob
+// authorizes the download until it expires
+// URL: GET /api/admin/exports/:id/download?expires=...&signature=...
+func DownloadExport(c fiber.Ctx) error {
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	if !util.VerifyPath(downloadPath(id), c.Query("expires"), c.Query("signature")) {
+		return apperrors.Forbidden("invalid or expired download link")
+	}
+
+	var job models.ExportJob
+	if err := database.DB.First(&job, id).Error; err != nil {
+		return apperrors.FromDB(err, "export")
+	}
+
+	if job.Status != models.ExportJobCompleted {
+		return apperrors.NotFound("export not found")
+	}
+
+	file, err := storage.Default.Open(c.Context(), job.Path)
+	if errors.Is(err, storage.ErrNotFound) {
+		return apperrors.NotFound("export not found")
+	}
+	if err != nil {
+		return err
+	}
+
+	format := path.Ext(job.Path)[1:]
+	c.Attachment(export.Filename(format, job.CreatedAt))
+	c.Set(fiber.HeaderContentType, export.ContentType(format))
+
+	// The stream is closed once it has been sent
+	return c.SendStream(file)
+}
+
+// newExportJobResponse adds the progress and, for completed jobs, a freshly
+// signed download link to job
+func newExportJobResponse(job *models.ExportJob) ExportJobResponse {
+	response := ExportJobResponse{ExportJob: *job, Progress: job.Progress()}
+
+	if job.Status == models.ExportJobCompleted {
+		url, expiresAt := util.SignPath(downloadPath(job.Id), export.DownloadTTL())
+		response.DownloadURL = url
+		response.ExpiresAt = &expiresAt
+	}
+
+	return response
+}
+
+// downloadPath is the path of the download route of an export job
+func downloadPath(id uint) string {
+	return fmt.Sprintf("/api/admin/exports/%d/download", id)
+}
@@ -0,0 +1,101 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"go-ambassador/src/controllers"
+	"go-ambassador/src/export"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// completedExport queues an export through the API and waits for a worker to
+// complete it
+func completedExport(t *testing.T, client *testutil.Client) controllers.ExportJobResponse {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := export.StartWorkers(ctx, 1)
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+
+	res := client.Do(http.MethodPost, "/api/admin/exports?format=csv", nil)
+	if res.Status != http.StatusAccepted {
+		t.Fatalf("create: status %d: %s", res.Status, res.Body)
+	}
+
+	var job controllers.ExportJobResponse
+	res.Decode(t, &job)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != models.ExportJobCompleted {
+		if job.Status == models.ExportJobFailed || time.Now().After(deadline) {
+			t.Fatalf("job %+v: want completed", job)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		res := client.Do(http.MethodGet, fmt.Sprintf("/api/admin/exports/%d", job.Id), nil)
+		if res.Status != http.StatusOK {
+			t.Fatalf("get: status %d: %s", res.Status, res.Body)
+		}
+		res.Decode(t, &job)
+	}
+
+	return job
+}
+
+func TestExportDownloadIsSigned(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	order := models.Order{FirstName: "Buyer", LastName: "One", Email: "buyer@example.com", Status: models.OrderStatusPaid,
+		OrderItems: []models.OrderItem{{ProductTitle: "Mug", Price: 10, Quantity: 2}}}
+	if err := env.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	job := completedExport(t, admin)
+	if job.DownloadURL == "" || job.ExpiresAt == nil {
+		t.Fatalf("completed job without a download link: %+v", job)
+	}
+
+	// The signature alone authorizes the download
+	res := env.Client(t).Do(http.MethodGet, job.DownloadURL, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("download: status %d: %s", res.Status, res.Body)
+	}
+	if !strings.HasPrefix(res.Header.Get("Content-Type"), "text/csv") {
+		t.Errorf("content type %q", res.Header.Get("Content-Type"))
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(res.Body))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[2][3] != "Mug" {
+		t.Errorf("file rows %q", records)
+	}
+
+	path := fmt.Sprintf("/api/admin/exports/%d/download", job.Id)
+	expired, _ := util.SignPath(path, -time.Minute)
+	other, _ := util.SignPath(fmt.Sprintf("/api/admin/exports/%d/download", job.Id+1), time.Hour)
+
+	for name, url := range map[string]string{
+		"unsigned":           path,
+		"tampered":           strings.Replace(job.DownloadURL, "signature=", "signature=0", 1),
+		"expired":            expired,
+		"signed for another": path + other[strings.Index(other, "?"):],
+	} {
+		if res := env.Client(t).Do(http.MethodGet, url, nil); res.Status != http.StatusForbidden {
+			t.Errorf("%s: status %d, want 403", name, res.Status)
+		}
+	}
+}
@@ -1 +1,189 @@
 package controllers
+
+import (
+	"errors"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// linkCodeLength is the number of random characters in a link code
+const linkCodeLength = 12
+
+// linkCodeAttempts bounds how many codes are tried before giving up on a collision
+const linkCodeAttempts = 5
+
+// CreateLinkRequest is the body accepted by CreateLink
+type CreateLinkRequest struct {
+	Products []uint `json:"products" validate:"required,min=1"`
+}
+
+// CreateLink creates a new tracked link for the authenticated ambassador
+// The link references the given products and gets a random, unique code
+// URL: POST /api/ambassador/links
+func CreateLink(c fiber.Ctx) error {
+	var request CreateLinkRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Identify the ambassador from the JWT cookie
+	id, _ := util.ParseJWT(c.Cookies("jwt"))
+	userId, _ := strconv.Atoi(id)
+
+	var user models.User
+	if err := database.DB.Where("id = ?", userId).First(&user).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	// Only ambassadors are allowed to own links
+	if !user.IsAmbassador {
+		return apperrors.Forbidden("only ambassadors can create links")
+	}
+
+	// Links can only be created once the ambassador's email is verified
+	if !user.IsVerified() {
+		return apperrors.Forbidden("verify your email address before creating links")
+	}
+
+	// Every requested product must exist
+	products, err := findLinkProducts(request.Products)
+	if err != nil {
+		return err
+	}
+
+	// Generate a code that is not used by any other link yet
+	// The unique index on links.code is the final guard against races
+	code, err := generateLinkCode()
+	if err != nil {
+		return err
+	}
+
+	// Only the owner's ID is set; assigning User would make GORM upsert the
+	// user row along with the link
+	link := models.Link{
+		Code:     code,
+		UserId:   user.Id,
+		Products: products,
+	}
+
+	// Save the link together with its link_products rows
+	if err := database.DB.Create(&link).Error; err != nil {
+		return apperrors.FromDB(err, "link")
+	}
+
+	link.User = user
+
+	return c.JSON(link)
+}
+
+// Links returns a paginated list of links owned by the authenticated ambassador
+// Query parameters: page, per_page, sort (code)
+// URL: GET /api/ambassador/links
+func Links(c fiber.Ctx) error {
+	// Identify the ambassador from the JWT cookie
+	id, _ := util.ParseJWT(c.Cookies("jwt"))
+
+	// Scope pagination to the caller's links only
+	db := database.DB.Where("user_id = ?", id)
+
+	return paginate[models.Link](c, db, models.LinkPageOptions)
+}
+
+// PublicLink is the view of a link shown to buyers
+// Only the ambassador's name is exposed, never their email or account details
+type PublicLink struct {
+	Id       uint             `json:"id"`
+	Code     string           `json:"code"`
+	User     PublicAmbassador `json:"user"`
+	Products []models.Product `json:"products"`
+}
+
+// PublicAmbassador is the owner of a link as shown to buyers
+type PublicAmbassador struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// GetLink resolves a public link code to its ambassador and products
+// This is used by the checkout page and does not require authentication
+// URL: GET /api/checkout/links/:code
+func GetLink(c fiber.Ctx) error {
+	var link models.Link
+
+	// Look up the link by code and load the owner's name and the products
+	err := database.DB.
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "first_name", "last_name")
+		}).
+		Preload("Products").
+		Where("code = ?", c.Params("code")).
+		First(&link).Error
+
+	if err != nil {
+		return apperrors.FromDB(err, "link")
+	}
+
+	return c.JSON(PublicLink{
+		Id:   link.Id,
+		Code: link.Code,
+		User: PublicAmbassador{
+			FirstName: link.User.FirstName,
+			LastName:  link.User.LastName,
+		},
+		Products: link.Products,
+	})
+}
+
+// findLinkProducts loads the products with the given IDs
+// Returns a 400 error if the list is empty or any ID does not exist
+func findLinkProducts(ids []uint) ([]models.Product, error) {
+	if len(ids) == 0 {
+		return nil, apperrors.BadRequest("at least one product is required")
+	}
+
+	// Remove duplicate IDs so the existence check compares like with like
+	unique := make(map[uint]struct{}, len(ids))
+	for _, id := range ids {
+		unique[id] = struct{}{}
+	}
+
+	var products []models.Product
+	if err := database.DB.Where("id IN ?", ids).Find(&products).Error; err != nil {
+		return nil, err
+	}
+
+	if len(products) != len(unique) {
+		return nil, apperrors.BadRequest("one or more products do not exist")
+	}
+
+	return products, nil
+}
+
+// generateLinkCode returns a random code that is not yet used by any link
+func generateLinkCode() (string, error) {
+	for i := 0; i < linkCodeAttempts; i++ {
+		code, err := util.RandomCode(linkCodeLength)
+		if err != nil {
+			return "", err
+		}
+
+		var count int64
+		if err := database.DB.Model(&models.Link{}).Where("code = ?", code).Count(&count).Error; err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return code, nil
+		}
+	}
+
+	return "", errors.New("could not generate a unique link code")
+}
@@ -0,0 +1,187 @@
+package controllers_test
+
+import (
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifiedAmbassador creates an ambassador whose email is verified
+func verifiedAmbassador(t *testing.T) models.User {
+	now := time.Now()
+	return testutil.CreateUser(t, models.User{IsAmbassador: true, EmailVerifiedAt: &now})
+}
+
+// createProducts stores a product per title
+func createProducts(t *testing.T, env *testutil.Env, titles ...string) []models.Product {
+	products := make([]models.Product, len(titles))
+	for i, title := range titles {
+		products[i] = models.Product{Title: title, Price: float64(10 * (i + 1))}
+	}
+
+	if err := env.DB.Create(&products).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return products
+}
+
+// createLink creates a link for client through the API and returns it
+func createLink(t *testing.T, client *testutil.Client, products ...models.Product) models.Link {
+	t.Helper()
+
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.Id
+	}
+
+	res := client.Do(http.MethodPost, "/api/ambassador/links", map[string]interface{}{"products": ids})
+	if res.Status != http.StatusOK {
+		t.Fatalf("creating link: status %d: %s", res.Status, res.Body)
+	}
+
+	var link models.Link
+	res.Decode(t, &link)
+
+	return link
+}
+
+func TestCreateLink(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug", "Shirt")
+
+	user := verifiedAmbassador(t)
+	client := env.LoginAs(t, user, util.ScopeAmbassador)
+
+	link := createLink(t, client, products...)
+
+	if len(link.Code) != 12 {
+		t.Errorf("code %q: want 12 characters", link.Code)
+	}
+	if link.UserId != user.Id {
+		t.Errorf("user_id = %d, want %d", link.UserId, user.Id)
+	}
+
+	var stored models.Link
+	if err := env.DB.Preload("Products").First(&stored, link.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Code != link.Code || len(stored.Products) != 2 {
+		t.Errorf("stored link %+v: want code %s with 2 products", stored, link.Code)
+	}
+
+	// Creating a link must not write the owner back
+	var owner models.User
+	if err := env.DB.First(&owner, user.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if owner.Email != user.Email || owner.FirstName != user.FirstName {
+		t.Errorf("owner changed to %+v", owner)
+	}
+
+	// Codes are unique across links
+	other := createLink(t, client, products[0])
+	if other.Code == link.Code {
+		t.Errorf("two links share code %s", link.Code)
+	}
+}
+
+func TestCreateLinkRejects(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug")
+
+	unverified := testutil.CreateUser(t, models.User{IsAmbassador: true})
+	verified := verifiedAmbassador(t)
+
+	tests := []struct {
+		name   string
+		client *testutil.Client
+		body   interface{}
+		status int
+	}{
+		{"unauthenticated", env.Client(t), map[string]interface{}{"products": []uint{products[0].Id}}, http.StatusUnauthorized},
+		{"unverified", env.LoginAs(t, unverified, util.ScopeAmbassador), map[string]interface{}{"products": []uint{products[0].Id}}, http.StatusForbidden},
+		{"no products", env.LoginAs(t, verified, util.ScopeAmbassador), map[string]interface{}{"products": []uint{}}, http.StatusUnprocessableEntity},
+		{"unknown product", env.LoginAs(t, verified, util.ScopeAmbassador), map[string]interface{}{"products": []uint{products[0].Id, 999}}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := test.client.Do(http.MethodPost, "/api/ambassador/links", test.body)
+			if res.Status != test.status {
+				t.Errorf("status %d, want %d: %s", res.Status, test.status, res.Body)
+			}
+		})
+	}
+
+	var count int64
+	env.DB.Model(&models.Link{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d links stored, want none", count)
+	}
+}
+
+func TestLinksListsOwnLinksOnly(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug")
+
+	alice := env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+	bob := env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+
+	own := createLink(t, alice, products...)
+	createLink(t, bob, products...)
+
+	res := alice.Do(http.MethodGet, "/api/ambassador/links", nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var page models.Page[models.Link]
+	res.Decode(t, &page)
+
+	if len(page.Data) != 1 || page.Data[0].Code != own.Code {
+		t.Fatalf("links %+v: want only %s", page.Data, own.Code)
+	}
+	if len(page.Data[0].Products) != 1 {
+		t.Errorf("products of the link are not loaded")
+	}
+}
+
+func TestGetLink(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug", "Shirt")
+
+	owner := verifiedAmbassador(t)
+	link := createLink(t, env.LoginAs(t, owner, util.ScopeAmbassador), products...)
+
+	res := env.Client(t).Do(http.MethodGet, "/api/checkout/links/"+link.Code, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var public struct {
+		Code     string                 `json:"code"`
+		User     map[string]interface{} `json:"user"`
+		Products []models.Product       `json:"products"`
+	}
+	res.Decode(t, &public)
+
+	if public.Code != link.Code || len(public.Products) != 2 {
+		t.Errorf("link %+v: want code %s with 2 products", public, link.Code)
+	}
+	if public.User["first_name"] != owner.FirstName || len(public.User) != 2 {
+		t.Errorf("user %v: want only the ambassador's name", public.User)
+	}
+	if strings.Contains(string(res.Body), owner.Email) {
+		t.Errorf("response exposes the ambassador's email: %s", res.Body)
+	}
+
+	res = env.Client(t).Do(http.MethodGet, "/api/checkout/links/unknown", nil)
+	if res.Status != http.StatusNotFound {
+		t.Errorf("unknown code: status %d, want 404", res.Status)
+	}
+}
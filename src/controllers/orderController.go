@@ -1,98 +1,503 @@
 package controllers
 
 import (
-	"encoding/csv"
-	"golangProject/database"
-	"golangProject/models"
-	"os"
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/database"
+	"go-ambassador/src/export"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/payments"
+	"log"
+	"math"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
-// AllOrders returns a paginated list of all orders with their items
+// AllOrders returns a paginated list of all orders with their items, newest first
 // Uses the generic Paginate function for consistent pagination
-// Query parameter: page (defaults to 1) - specifies which page of results to return
+// Query parameters: page, per_page, sort (create_at, status, email) and cursor;
+// passing cursor (empty for the first page) switches to keyset pagination,
+// which avoids COUNT and OFFSET on large order tables
+// Filters: s (buyer name/email, link code, ambassador email), filter[status],
+// filter[email], filter[code], filter[user_id], created_from and created_to
 func AllOrders(c fiber.Ctx) error {
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-	return c.JSON(models.Paginate(database.DB, &models.Order{}, page))
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	return paginate[models.Order](c, database.DB, models.OrderPageOptions)
 }
 
-// Export generates a CSV file containing all orders and order items
-// Creates a structured export suitable for spreadsheets or data analysis
-// The CSV file is saved temporarily and sent as a download to the client
-func Export(c fiber.Ctx) error {
-	filePath := "./csv/order.csv"
+// CreateOrderRequest is the body accepted by CreateOrder
+type CreateOrderRequest struct {
+	Code      string                `json:"code" validate:"required"`
+	FirstName string                `json:"first_name" validate:"required,max=255"`
+	LastName  string                `json:"last_name" validate:"required,max=255"`
+	Email     string                `json:"email" validate:"required,email,max=255"`
+	Address   string                `json:"address" validate:"max=255"`
+	City      string                `json:"city" validate:"max=255"`
+	Country   string                `json:"country" validate:"max=255"`
+	Zip       string                `json:"zip" validate:"max=32"`
+	Products  []OrderProductRequest `json:"products" validate:"required,min=1,dive"`
+}
+
+// OrderProductRequest is a single product line in CreateOrderRequest
+type OrderProductRequest struct {
+	ProductId uint `json:"product_id" validate:"required"`
+	Quantity  uint `json:"quantity" validate:"required,gt=0"`
+}
+
+// CreateOrder places an order for the products of an ambassador's link
+// The order and all of its items are written in a single transaction, then a
+// checkout session is started with the payment provider
+// Each item snapshots the product title and price and records the revenue split
+// URL: POST /api/checkout/orders
+func CreateOrder(c fiber.Ctx) error {
+	var request CreateOrderRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Resolve the link code to its ambassador and the products it offers
+	var link models.Link
+	err := database.DB.Preload("User").Preload("Products").
+		Where("code = ?", request.Code).
+		First(&link).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.BadRequest("invalid link code")
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	// Build the line items, accepting only products that belong to the link
+	items, err := buildOrderItems(link, request.Products)
+	if err != nil {
+		return apperrors.BadRequest(err.Error())
+	}
+
+	order := models.Order{
+		UserId:          link.UserId,
+		Code:            link.Code,
+		AmbassadorEmail: link.User.Email,
+		FirstName:       request.FirstName,
+		LastName:        request.LastName,
+		Email:           request.Email,
+		Address:         request.Address,
+		City:            request.City,
+		Country:         request.Country,
+		Zip:             request.Zip,
+		Status:          models.OrderStatusPending,
+	}
+
+	// Insert the order and its items atomically
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+
+		for i := range items {
+			items[i].OrderId = order.Id
+		}
+
+		return tx.Create(&items).Error
+	})
+
+	if err != nil {
+		return err
+	}
 
-	// Create the CSV file and populate with order data
-	if err := CreateFile(filePath); err != nil {
+	order.OrderItems = items
+
+	// Start the hosted checkout only once the order is committed, so no
+	// transaction is held open while waiting on the provider
+	session, err := payments.Default.CreateCheckoutSession(c.Context(), checkoutSessionParams(order))
+	if err != nil {
+		abandonOrder(&order)
 		return err
 	}
 
-	// Send the generated file to client as a download
-	return c.Download(filePath)
+	// Remember the session so the order can be confirmed later
+	order.TransactionId = session.Id
+
+	if err := database.DB.Model(&order).Update("transaction_id", session.Id).Error; err != nil {
+		abandonOrder(&order)
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"order": order,
+		"url":   session.URL,
+	})
+}
+
+// abandonOrder cancels a pending order whose checkout could not be started,
+// so it is never left waiting for a payment that cannot happen
+func abandonOrder(order *models.Order) {
+	if err := order.Transition(models.OrderStatusCancelled); err != nil {
+		return
+	}
+
+	if err := saveOrderTransition(order, models.OrderStatusPending); err != nil {
+		log.Printf("order %d: cancelling abandoned order: %v", order.Id, err)
+	}
+}
+
+// ConfirmOrderRequest is the body accepted by ConfirmOrder
+type ConfirmOrderRequest struct {
+	Source string `json:"source" validate:"required"`
 }
 
-// CreateFile generates the actual CSV file with order data
-// File structure:
-//   - Header row with column names
-//   - Each order spans multiple rows: one main row + one per order item
-//   - Empty cells used for visual grouping of order items under their order
-func CreateFile(filePath string) error {
-	// Create the CSV file
-	file, err := os.Create(filePath)
+// ConfirmOrder marks an order paid once the payment provider reports it paid
+// Source is the checkout session ID returned by CreateOrder
+// URL: POST /api/checkout/orders/confirm
+func ConfirmOrder(c fiber.Ctx) error {
+	var request ConfirmOrderRequest
 
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Find the order that owns the checkout session
+	var order models.Order
+	if err := database.DB.Preload("OrderItems").Where("transaction_id = ?", request.Source).First(&order).Error; err != nil {
+		return apperrors.FromDB(err, "order")
+	}
+
+	// Confirming twice is harmless
+	if order.Status == models.OrderStatusPaid {
+		return c.JSON(fiber.Map{
+			"message": "success",
+		})
+	}
+
+	// Reject confirmation of cancelled or refunded orders before calling the provider
+	if !order.CanTransition(models.OrderStatusPaid) {
+		return orderConflict(&models.TransitionError{From: order.Status, To: models.OrderStatusPaid})
+	}
+
+	// Ask the provider whether the buyer actually paid
+	confirmation, err := payments.Default.Confirm(c.Context(), request.Source)
 	if err != nil {
 		return err
 	}
 
-	defer file.Close() // Ensure file is closed when function exits
+	if !confirmation.Paid {
+		return apperrors.New(fiber.StatusPaymentRequired, "payment has not been completed")
+	}
 
-	// Create CSV writer with buffering
-	writer := csv.NewWriter(file)
-	defer writer.Flush() // Ensure all data is written to file
+	// The buyer must have paid exactly what the order is worth
+	if expected := checkoutSessionParams(order).Total(); confirmation.AmountTotal != expected {
+		log.Printf("order %d: paid %d but the order total is %d", order.Id, confirmation.AmountTotal, expected)
+		return apperrors.Conflict("payment amount does not match the order total")
+	}
 
-	var orders []models.Order
+	// Only now does the order count as paid
+	from := order.Status
+	order.PaymentId = confirmation.PaymentId
+	if err := order.Transition(models.OrderStatusPaid); err != nil {
+		return orderConflict(err)
+	}
 
-	// Load all orders with their associated items from database
-	database.DB.Preload("OrderItems").Find(&orders)
+	if err := saveOrderTransition(&order, from); err != nil {
+		return orderConflict(err)
+	}
+
+	// Credit the ambassador on the leaderboard
+	// A Redis failure is only logged; updateRankings repairs any drift
+	if err := database.IncrementRanking(c.Context(), order.UserId, order.AmbassadorRevenue()); err != nil {
+		log.Println("failed to update rankings:", err)
+	}
 
-	// Write CSV header row
-	writer.Write([]string{
-		"ID", "Name", "Email", "Product Title", "Price", "Quantity",
+	return c.JSON(fiber.Map{
+		"message": "success",
 	})
+}
+
+// CancelOrder cancels an order that has not been paid yet
+// URL: POST /api/admin/orders/:id/cancel
+func CancelOrder(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	// Extract the order ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var order models.Order
+	if err := database.DB.Where("id = ?", id).First(&order).Error; err != nil {
+		return apperrors.FromDB(err, "order")
+	}
+
+	from := order.Status
+	if err := order.Transition(models.OrderStatusCancelled); err != nil {
+		return orderConflict(err)
+	}
+
+	if err := saveOrderTransition(&order, from); err != nil {
+		return orderConflict(err)
+	}
+
+	return c.JSON(order)
+}
+
+// RefundOrderRequest is the body accepted by RefundOrder
+// Amount defaults to the full remaining order total when omitted
+type RefundOrderRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"`
+}
+
+// RefundOrder refunds all or part of a paid order through the payment provider
+// A refund covering the remaining total moves the order to refunded,
+// anything less to partially_refunded
+// URL: POST /api/admin/orders/:id/refund
+func RefundOrder(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	var request RefundOrderRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Extract the order ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var order models.Order
+	if err := database.DB.Preload("OrderItems").Where("id = ?", id).First(&order).Error; err != nil {
+		return apperrors.FromDB(err, "order")
+	}
 
-	// Write order data to CSV
-	for _, order := range orders {
-		// Write the main order row (shows order info, empty product columns)
-		data := []string{
-			strconv.Itoa(int(order.Id)),
-			order.FirstName + " " + order.LastName,
-			order.Email,
-			"",
-			"",
-			"",
+	// Work in cents to avoid floating point drift on the remaining balance
+	remaining := toCents(order.Total()) - toCents(order.RefundedAmount)
+	amount := toCents(request.Amount)
+	if amount == 0 {
+		amount = remaining
+	}
+
+	// Decide the target status first so invalid requests never reach the provider
+	to := models.OrderStatusPartiallyRefunded
+	if amount >= remaining {
+		to = models.OrderStatusRefunded
+	}
+
+	if !order.CanTransition(to) {
+		return orderConflict(&models.TransitionError{From: order.Status, To: to})
+	}
+
+	if amount <= 0 || amount > remaining {
+		return apperrors.BadRequest("refund amount must be between 0 and the remaining order total")
+	}
+
+	if _, err := payments.Default.Refund(c.Context(), order.PaymentId, amount); err != nil {
+		return err
+	}
+
+	from := order.Status
+	order.RefundedAmount += float64(amount) / 100
+	if err := order.Transition(to); err != nil {
+		return orderConflict(err)
+	}
+
+	if err := saveOrderTransition(&order, from); err != nil {
+		return orderConflict(err)
+	}
+
+	// Rankings only count paid orders, so the first refund removes the whole order
+	if from == models.OrderStatusPaid {
+		if err := database.IncrementRanking(c.Context(), order.UserId, -order.AmbassadorRevenue()); err != nil {
+			log.Println("failed to update rankings:", err)
 		}
-		if err := writer.Write(data); err != nil {
-			return err
+	}
+
+	return c.JSON(order)
+}
+
+// saveOrderTransition persists a status change made with Order.Transition
+// The update only applies if the row is still in the from status, so two
+// concurrent transitions cannot both succeed
+func saveOrderTransition(order *models.Order, from string) error {
+	result := database.DB.Model(&models.Order{}).
+		Where("id = ? AND status = ?", order.Id, from).
+		Select("status", "payment_id", "refunded_amount", "paid_at", "cancelled_at", "refunded_at").
+		Updates(order)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return &models.TransitionError{From: from, To: order.Status}
+	}
+
+	return nil
+}
+
+// orderConflict converts an invalid status transition into a 409 error whose
+// details name the from and to statuses
+// Any other error is returned unchanged
+func orderConflict(err error) error {
+	var transitionErr *models.TransitionError
+	if !errors.As(err, &transitionErr) {
+		return err
+	}
+
+	return apperrors.Conflict(transitionErr.Error()).WithDetails(fiber.Map{
+		"from": transitionErr.From,
+		"to":   transitionErr.To,
+	})
+}
+
+// toCents converts an amount in currency units to the smallest currency unit
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// checkoutSessionParams describes the order's items for the payment provider
+func checkoutSessionParams(order models.Order) payments.SessionParams {
+	lineItems := make([]payments.LineItem, 0, len(order.OrderItems))
+
+	for _, item := range order.OrderItems {
+		lineItems = append(lineItems, payments.LineItem{
+			Name:       item.ProductTitle,
+			UnitAmount: toCents(item.Price),
+			Quantity:   int64(item.Quantity),
+		})
+	}
+
+	return payments.SessionParams{
+		Reference:  strconv.Itoa(int(order.Id)),
+		LineItems:  lineItems,
+		SuccessURL: payments.CheckoutURL + "/success?source={CHECKOUT_SESSION_ID}",
+		CancelURL:  payments.CheckoutURL + "/error",
+	}
+}
+
+// buildOrderItems converts the requested products into order items
+// Returns an error if a product is not part of the link or a quantity is zero
+func buildOrderItems(link models.Link, requested []OrderProductRequest) ([]models.OrderItem, error) {
+	if len(requested) == 0 {
+		return nil, errors.New("at least one product is required")
+	}
+
+	// Index the link's products by ID for quick lookups
+	products := make(map[uint]models.Product, len(link.Products))
+	for _, product := range link.Products {
+		products[product.Id] = product
+	}
+
+	items := make([]models.OrderItem, 0, len(requested))
+
+	for _, line := range requested {
+		product, ok := products[line.ProductId]
+		if !ok {
+			return nil, fmt.Errorf("product %d is not part of this link", line.ProductId)
 		}
 
-		// Write each order item as a separate row
-		for _, orderItem := range order.OrderItems {
-			data := []string{
-				"",
-				"",
-				"",
-				orderItem.ProductTitle,
-				strconv.Itoa(int(orderItem.Price)),
-				strconv.Itoa(int(orderItem.Quantity)),
-			}
-			if err := writer.Write(data); err != nil {
-				return err
-			}
+		if line.Quantity == 0 {
+			return nil, fmt.Errorf("quantity for product %d must be at least 1", line.ProductId)
 		}
+
+		items = append(items, models.NewOrderItem(product, line.Quantity))
 	}
-	return nil
+
+	return items, nil
+}
+
+// Export streams orders as a download
+// Query parameters:
+//   - format: csv (default), xlsx or ndjson
+//   - layout: grouped (default) writes an order row followed by its item rows,
+//     flat writes one row per item with the order columns repeated
+//   - filter[status]: comma separated statuses, paid by default
+//   - created_from, created_to: YYYY-MM-DD or RFC 3339 bounds on create_at
+//
+// Orders are read in batches and written as they are loaded, so the export
+// never holds every order in memory
+// URL: POST /api/admin/export
+func Export(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	options, err := exportOptions(c)
+	if err != nil {
+		return err
+	}
+
+	c.Attachment(export.Filename(options.Format, time.Now()))
+	c.Set(fiber.HeaderContentType, export.ContentType(options.Format))
+
+	// The writer runs after the handler returns and c is released, so take the
+	// context now; Fiber does not cancel it when the client disconnects, so
+	// the writer cancels it on the first failed write or flush instead, which
+	// aborts the running query and stops the export before the next batch
+	ctx, cancel := context.WithCancel(c.Context())
+
+	// The status line is sent before the first row, so a failure part way
+	// through can only be logged and cuts the download short
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		if err := export.Orders(ctx, database.DB, export.NewCancelWriter(w, cancel), options); err != nil {
+			log.Printf("export: %v", err)
+		}
+	})
+}
+
+// exportOptions reads the export format, layout and filters from the query string
+// Returns a 400 error for unknown values or malformed dates
+func exportOptions(c fiber.Ctx) (export.OrderOptions, error) {
+	options := export.OrderOptions{
+		Format:   c.Query("format", export.FormatCSV),
+		Layout:   c.Query("layout", export.LayoutGrouped),
+		Statuses: []string{models.OrderStatusPaid},
+	}
+
+	if raw := c.Query("filter[status]"); raw != "" {
+		options.Statuses = strings.Split(raw, ",")
+	}
+
+	if raw := c.Query("created_from"); raw != "" {
+		from, _, err := models.ParseTimeBound(raw, false)
+		if err != nil {
+			return options, apperrors.BadRequest(fmt.Sprintf("invalid created_from: %v", err))
+		}
+		options.From = &from
+	}
+
+	if raw := c.Query("created_to"); raw != "" {
+		to, exclusive, err := models.ParseTimeBound(raw, true)
+		if err != nil {
+			return options, apperrors.BadRequest(fmt.Sprintf("invalid created_to: %v", err))
+		}
+		options.To = &to
+		options.ToExclusive = exclusive
+	}
+
+	return options, options.Validate()
 }
 
 // Sales represents daily sales data for chart visualization
@@ -102,19 +507,42 @@ type Sales struct {
 	Sum  string `json:"sum"`
 }
 
+// chartCacheKey holds the cached daily sales of Chart
+const chartCacheKey = "chart:sales"
+
+// chartCacheTTL bounds how stale the chart can get if an invalidation is missed
+const chartCacheTTL = 10 * time.Minute
+
 // Chart returns daily sales data for visualization
 // Uses raw SQL to group sales by date and calculate daily totals
 // Returns data suitable for line charts or sales trend analysis
+// The result is cached until an order is written
 func Chart(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "orders"); err != nil {
+		return err
+	}
+
+	sales, err := cache.Remember(c.Context(), chartCacheKey, chartCacheTTL, []string{models.OrdersCacheTag}, loadSales)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(sales)
+}
+
+// loadSales sums the revenue of paid orders per day
+func loadSales(ctx context.Context) ([]Sales, error) {
 	var sales []Sales
 
 	// Execute raw SQL query to get daily sales totals
-	// Groups orders by creation date and sums the product of price * quantity
-	database.DB.Raw(`
+	// Groups paid orders by creation date and sums the product of price * quantity
+	err := database.DB.WithContext(ctx).Raw(`
 		SELECT DATE_FORMAT(o.create_at, '%Y-%m-%d') as date, SUM(oi.price*oi.quantity) as sum 
 		FROM orders o 
 		JOIN order_items oi on o.id=oi.order_id 
+		WHERE o.status = ?
 		GROUP BY date
-		`).Scan(&sales)
-	return c.JSON(sales)
+		`, models.OrderStatusPaid).Scan(&sales).Error
+
+	return sales, err
 }
@@ -0,0 +1,307 @@
+package controllers_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ambassador/src/models"
+	"go-ambassador/src/payments"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+// checkoutResponse is the body returned by CreateOrder
+type checkoutResponse struct {
+	Order models.Order `json:"order"`
+	URL   string       `json:"url"`
+}
+
+// checkout places an order for one of each product through the link
+func checkout(t *testing.T, env *testutil.Env, link models.Link, products ...models.Product) checkoutResponse {
+	t.Helper()
+
+	lines := make([]map[string]interface{}, len(products))
+	for i, product := range products {
+		lines[i] = map[string]interface{}{"product_id": product.Id, "quantity": 1}
+	}
+
+	res := env.Client(t).Do(http.MethodPost, "/api/checkout/orders", map[string]interface{}{
+		"code":       link.Code,
+		"first_name": "Buyer",
+		"last_name":  "Person",
+		"email":      "buyer@example.com",
+		"products":   lines,
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("checkout: status %d: %s", res.Status, res.Body)
+	}
+
+	var checkout checkoutResponse
+	res.Decode(t, &checkout)
+
+	return checkout
+}
+
+// orderStatus reloads the status of the order
+func orderStatus(t *testing.T, env *testutil.Env, id uint) string {
+	t.Helper()
+
+	var order models.Order
+	if err := env.DB.First(&order, id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return order.Status
+}
+
+// confirm posts the checkout session ID to ConfirmOrder
+func confirm(t *testing.T, env *testutil.Env, source string) *testutil.Response {
+	return env.Client(t).Do(http.MethodPost, "/api/checkout/orders/confirm", map[string]string{"source": source})
+}
+
+func TestConfirmOrder(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug", "Shirt")
+	link := createLink(t, env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador), products...)
+
+	placed := checkout(t, env, link, products...)
+	if placed.Order.Status != models.OrderStatusPending || placed.Order.TransactionId == "" {
+		t.Fatalf("order %+v: want a pending order with a checkout session", placed.Order)
+	}
+
+	// A declined payment leaves the order pending
+	env.Payments.Decline(placed.Order.TransactionId)
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusPaymentRequired {
+		t.Errorf("declined: status %d, want 402: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusPending {
+		t.Errorf("declined: status %s, want pending", status)
+	}
+
+	placed = checkout(t, env, link, products...)
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusPaid {
+		t.Errorf("status %s, want paid", status)
+	}
+
+	// Confirming again is harmless
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusOK {
+		t.Errorf("second confirmation: status %d: %s", res.Status, res.Body)
+	}
+}
+
+func TestConfirmOrderRejectsAmountMismatch(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug")
+	link := createLink(t, env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador), products...)
+
+	placed := checkout(t, env, link, products...)
+
+	// The provider charged the original price, the order now claims more
+	err := env.DB.Model(&models.OrderItem{}).Where("order_id = ?", placed.Order.Id).Update("price", 99).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusConflict {
+		t.Errorf("status %d, want 409: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusPending {
+		t.Errorf("status %s, want pending", status)
+	}
+}
+
+// unavailableProvider fails every checkout session
+type unavailableProvider struct {
+	payments.Provider
+}
+
+func (unavailableProvider) CreateCheckoutSession(ctx context.Context, params payments.SessionParams) (*payments.Session, error) {
+	return nil, errors.New("provider unavailable")
+}
+
+func TestCreateOrderCancelsWhenProviderFails(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug")
+	link := createLink(t, env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador), products...)
+
+	payments.Default = unavailableProvider{}
+
+	res := env.Client(t).Do(http.MethodPost, "/api/checkout/orders", map[string]interface{}{
+		"code":       link.Code,
+		"first_name": "Buyer",
+		"last_name":  "Person",
+		"email":      "buyer@example.com",
+		"products":   []map[string]interface{}{{"product_id": products[0].Id, "quantity": 1}},
+	})
+	if res.Status != http.StatusInternalServerError {
+		t.Errorf("status %d, want 500: %s", res.Status, res.Body)
+	}
+
+	var orders []models.Order
+	if err := env.DB.Preload("OrderItems").Find(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Status != models.OrderStatusCancelled || len(orders[0].OrderItems) != 1 {
+		t.Errorf("orders %+v: want one cancelled order with its item", orders)
+	}
+}
+
+func TestOrderLifecycleWithStripe(t *testing.T) {
+	env := testutil.Setup(t)
+	stripe := testutil.NewStripe(t)
+
+	products := createProducts(t, env, "Mug", "Shirt")
+	link := createLink(t, env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador), products...)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	placed := checkout(t, env, link, products...)
+	if placed.URL != "https://checkout.stripe.test/"+placed.Order.TransactionId {
+		t.Errorf("url %q does not point at the hosted checkout", placed.URL)
+	}
+
+	// The buyer has not paid yet
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusPaymentRequired {
+		t.Fatalf("unpaid: status %d, want 402: %s", res.Status, res.Body)
+	}
+
+	stripe.Pay(placed.Order.TransactionId)
+	if res := confirm(t, env, placed.Order.TransactionId); res.Status != http.StatusOK {
+		t.Fatalf("paid: status %d: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusPaid {
+		t.Fatalf("status %s, want paid", status)
+	}
+
+	refund := func(body interface{}) *testutil.Response {
+		return admin.Do(http.MethodPost, fmt.Sprintf("/api/admin/orders/%d/refund", placed.Order.Id), body)
+	}
+
+	// Products cost 10 and 20, so 5 leaves 25 to refund
+	if res := refund(map[string]float64{"amount": 5}); res.Status != http.StatusOK {
+		t.Fatalf("partial refund: status %d: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusPartiallyRefunded {
+		t.Errorf("status %s, want partially_refunded", status)
+	}
+
+	if res := refund(map[string]float64{"amount": 30}); res.Status != http.StatusBadRequest {
+		t.Errorf("refund above the remaining total: status %d, want 400: %s", res.Status, res.Body)
+	}
+
+	if res := refund(map[string]float64{}); res.Status != http.StatusOK {
+		t.Fatalf("full refund: status %d: %s", res.Status, res.Body)
+	}
+	if status := orderStatus(t, env, placed.Order.Id); status != models.OrderStatusRefunded {
+		t.Errorf("status %s, want refunded", status)
+	}
+
+	var order models.Order
+	env.DB.First(&order, placed.Order.Id)
+	if refunded := stripe.Refunded(order.PaymentId); refunded != 3000 {
+		t.Errorf("stripe refunded %d, want 3000", refunded)
+	}
+
+	// Unknown sessions are not found on either side
+	if res := confirm(t, env, "cs_test_unknown"); res.Status != http.StatusNotFound {
+		t.Errorf("unknown session: status %d, want 404", res.Status)
+	}
+}
+
+func TestExportStreamsPaidOrders(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug", "Shirt")
+	link := createLink(t, env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador), products...)
+
+	paid := checkout(t, env, link, products...)
+	if res := confirm(t, env, paid.Order.TransactionId); res.Status != http.StatusOK {
+		t.Fatalf("confirming: status %d: %s", res.Status, res.Body)
+	}
+	checkout(t, env, link, products[0])
+
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	res := admin.Do(http.MethodPost, "/api/admin/export?format=ndjson", nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var orders []models.Order
+	scanner := bufio.NewScanner(bytes.NewReader(res.Body))
+	for scanner.Scan() {
+		var order models.Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		orders = append(orders, order)
+	}
+
+	// Only the paid order is exported by default, with its items
+	if len(orders) != 1 || orders[0].Id != paid.Order.Id || len(orders[0].OrderItems) != 2 {
+		t.Errorf("exported %+v, want only order %d with 2 items", orders, paid.Order.Id)
+	}
+}
+
+func TestOrdersFilter(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	day := time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		{Email: "a@example.com", Status: models.OrderStatusPaid, CreateAt: day.Add(-time.Hour)},
+		{Email: "b@example.com", Status: models.OrderStatusPaid, CreateAt: day},
+		{Email: "c@example.com", Status: models.OrderStatusRefunded, CreateAt: day.Add(23 * time.Hour)},
+		{Email: "d@example.com", Status: models.OrderStatusPending, CreateAt: day.AddDate(0, 0, 1)},
+	}
+	if err := env.DB.Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"filter[status]=paid", []string{"b@example.com", "a@example.com"}},
+		{"filter[status]=paid,refunded", []string{"c@example.com", "b@example.com", "a@example.com"}},
+		{"filter[email]=d@example.com", []string{"d@example.com"}},
+		// A date-only upper bound covers the whole day
+		{"created_from=2026-03-14&created_to=2026-03-14", []string{"c@example.com", "b@example.com"}},
+		{"created_to=2026-03-13", []string{"a@example.com"}},
+		{"created_from=2026-03-14T23:00:00Z", []string{"d@example.com", "c@example.com"}},
+		{"created_from=2026-03-14&filter[status]=paid", []string{"b@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res := admin.Do(http.MethodGet, "/api/admin/orders?"+tt.query, nil)
+			if res.Status != http.StatusOK {
+				t.Fatalf("status %d: %s", res.Status, res.Body)
+			}
+
+			var page models.Page[models.Order]
+			res.Decode(t, &page)
+
+			emails := make([]string, 0, len(page.Data))
+			for _, order := range page.Data {
+				emails = append(emails, order.Email)
+			}
+			if !slices.Equal(emails, tt.want) || *page.Meta.Total != int64(len(tt.want)) {
+				t.Errorf("orders %q of %d, want %q", emails, *page.Meta.Total, tt.want)
+			}
+		})
+	}
+
+	for _, query := range []string{"filter[amount]=1", "created_from=yesterday", "created_to=14/03/2026"} {
+		if res := admin.Do(http.MethodGet, "/api/admin/orders?"+query, nil); res.Status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, res.Status)
+		}
+	}
+}
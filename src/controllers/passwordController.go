@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/mail"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// resetTokenTTL is how long a forgot password link stays valid
+const resetTokenTTL = time.Hour
+
+// inviteTokenTTL is how long an invitation link stays valid
+const inviteTokenTTL = 72 * time.Hour
+
+// errInvalidPasswordToken is returned for unknown, used or expired reset tokens
+var errInvalidPasswordToken = apperrors.BadRequest("invalid or expired token")
+
+// EmailRequest is the body accepted by endpoints that only take an email address
+type EmailRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// Forgot emails a password reset link to the account with the given email
+// The response is the same whether or not the account exists so the endpoint
+// cannot be used to discover registered emails
+// URL: POST /api/forgot
+func Forgot(c fiber.Ctx) error {
+	var request EmailRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// A missing account is not an error, the response must not reveal it
+	var user models.User
+	err := database.DB.Where("email = ?", request.Email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if user.Id != 0 {
+		if err := sendPasswordToken(c, user, models.PasswordTokenReset); err != nil {
+			// Log instead of failing so the response does not reveal the account
+			log.Println("failed to send password reset:", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "if the email is registered, a reset link has been sent",
+	})
+}
+
+// ResetRequest is the body accepted by Reset
+type ResetRequest struct {
+	Token           string `json:"token" validate:"required"`
+	Password        string `json:"password" validate:"required,min=8,max=72"`
+	PasswordConfirm string `json:"password_confirm" validate:"required,eqfield=Password"`
+}
+
+// Reset sets a new password using a token from a reset or invitation email
+// The token can only be used once; all existing sessions of the user are revoked
+// URL: POST /api/reset
+func Reset(c fiber.Ctx) error {
+	var request ResetRequest
+
+	// Parse and validate the request body, including the password confirmation
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	var token models.PasswordToken
+	err := database.DB.Where("token_hash = ?", util.HashToken(request.Token)).First(&token).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	now := time.Now()
+
+	if token.Id == 0 || token.UsedAt != nil || now.After(token.ExpiresAt) {
+		return errInvalidPasswordToken
+	}
+
+	user := models.User{
+		Id: token.UserId,
+	}
+
+	user.SetPassword(request.Password)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Consume the token; the condition stops two concurrent requests from both using it
+		// and a failed password update below puts it back
+		result := tx.Model(&models.PasswordToken{}).
+			Where("id = ? AND used_at IS NULL", token.Id).
+			Update("used_at", now)
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return errInvalidPasswordToken
+		}
+
+		// Update only the password column
+		if err := tx.Model(&user).Update("password", user.Password).Error; err != nil {
+			return err
+		}
+
+		// Receiving the emailed token proves the user owns the address
+		err := tx.Model(&models.User{}).
+			Where("id = ? AND email_verified_at IS NULL", user.Id).
+			Update("email_verified_at", now).Error
+		if err != nil {
+			return err
+		}
+
+		// Sign the user out everywhere, a reset usually means the old password leaked
+		return tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", user.Id).
+			Update("revoked_at", now).Error
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "success reset",
+	})
+}
+
+// sendPasswordToken issues a new single-use token for the user and emails it
+func sendPasswordToken(c fiber.Ctx, user models.User, purpose string) error {
+	token, err := issuePasswordToken(database.DB, user, purpose)
+	if err != nil {
+		return err
+	}
+
+	return emailPasswordToken(c, user, purpose, token)
+}
+
+// issuePasswordToken stores a new single-use token for the user and returns it
+// Any earlier unused token of the user is invalidated first
+// The token is stored through db so callers can issue it inside a transaction;
+// the email is sent separately so no transaction waits on the mail server
+func issuePasswordToken(db *gorm.DB, user models.User, purpose string) (string, error) {
+	token, err := util.RandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	err = db.Model(&models.PasswordToken{}).
+		Where("user_id = ? AND used_at IS NULL", user.Id).
+		Update("used_at", now).Error
+	if err != nil {
+		return "", err
+	}
+
+	record := models.PasswordToken{
+		UserId:    user.Id,
+		Purpose:   purpose,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: now.Add(passwordTokenTTL(purpose)),
+	}
+
+	if err := db.Create(&record).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// emailPasswordToken emails the reset or invitation link carrying token
+func emailPasswordToken(c fiber.Ctx, user models.User, purpose string, token string) error {
+	ttl := passwordTokenTTL(purpose)
+	link := mail.Link("/reset", url.Values{"token": {token}})
+
+	message := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\nIf you did not ask for this, you can ignore this email.\n",
+			user.FirstName, ttl, link),
+	}
+
+	if purpose == models.PasswordTokenInvite {
+		message.Subject = "You have been invited to Ambassador"
+		message.Body = fmt.Sprintf("Hi %s,\n\nAn account has been created for you. Use the link below to set your password. It expires in %s.\n\n%s\n",
+			user.FirstName, ttl, link)
+	}
+
+	return mail.Send(c.Context(), message)
+}
+
+// passwordTokenTTL returns how long a token issued for purpose stays valid
+func passwordTokenTTL(purpose string) time.Duration {
+	if purpose == models.PasswordTokenInvite {
+		return inviteTokenTTL
+	}
+	return resetTokenTTL
+}
@@ -0,0 +1,177 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"go-ambassador/src/mail"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// failingMailer refuses every message, like an SMTP server that is down
+type failingMailer struct{}
+
+func (failingMailer) Send(ctx context.Context, message mail.Message) error {
+	return errors.New("smtp unavailable")
+}
+
+// tokenPattern finds the token in an emailed reset or invitation link
+var tokenPattern = regexp.MustCompile(`token=(\S+)`)
+
+// emailedToken returns the token from the last email sent to the address
+func emailedToken(t *testing.T, env *testutil.Env, to string) string {
+	t.Helper()
+
+	message, ok := env.Mail.Last(to)
+	if !ok {
+		t.Fatalf("no email sent to %s", to)
+	}
+
+	match := tokenPattern.FindStringSubmatch(message.Body)
+	if match == nil {
+		t.Fatalf("no token in %q", message.Body)
+	}
+
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// reset sets a new password with the token
+func reset(t *testing.T, env *testutil.Env, token string) *testutil.Response {
+	return env.Client(t).Do(http.MethodPost, "/api/reset", map[string]string{
+		"token":            token,
+		"password":         "new-password",
+		"password_confirm": "new-password",
+	})
+}
+
+// committedMailer records whether the invited user and their token were
+// committed when the invitation was sent
+type committedMailer struct {
+	*mail.Memory
+	db        *gorm.DB
+	committed bool
+}
+
+func (m *committedMailer) Send(ctx context.Context, message mail.Message) error {
+	var user models.User
+	if err := m.db.Where("email = ?", message.To).First(&user).Error; err == nil {
+		var tokens int64
+		m.db.Model(&models.PasswordToken{}).Where("user_id = ? AND used_at IS NULL", user.Id).Count(&tokens)
+		m.committed = tokens == 1
+	}
+
+	return m.Memory.Send(ctx, message)
+}
+
+func TestCreateUserSendsInviteAfterCommit(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	mailer := &committedMailer{Memory: env.Mail, db: env.DB}
+	mail.Default = mailer
+	defer func() { mail.Default = env.Mail }()
+
+	res := admin.Do(http.MethodPost, "/api/admin/users", map[string]interface{}{
+		"first_name": "Invited",
+		"last_name":  "User",
+		"email":      "invited@example.com",
+		"role_id":    3,
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	if !mailer.committed {
+		t.Error("invitation sent before the user and token were committed")
+	}
+}
+
+func TestCreateUserRemovesUserWhenInviteFails(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	invite := map[string]interface{}{
+		"first_name": "Invited",
+		"last_name":  "User",
+		"email":      "invited@example.com",
+		"role_id":    3,
+	}
+
+	mail.Default = failingMailer{}
+	res := admin.Do(http.MethodPost, "/api/admin/users", invite)
+	mail.Default = env.Mail
+
+	if res.Status != http.StatusBadGateway {
+		t.Fatalf("status %d, want 502: %s", res.Status, res.Body)
+	}
+
+	var count int64
+	env.DB.Model(&models.User{}).Where("email = ?", "invited@example.com").Count(&count)
+	if count != 0 {
+		t.Fatal("user kept although the invitation was not sent")
+	}
+
+	env.DB.Model(&models.PasswordToken{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d invitation tokens kept", count)
+	}
+
+	// Retrying once the mailer is back does not hit a duplicate email
+	res = admin.Do(http.MethodPost, "/api/admin/users", invite)
+	if res.Status != http.StatusOK {
+		t.Fatalf("retry: status %d: %s", res.Status, res.Body)
+	}
+
+	if res := reset(t, env, emailedToken(t, env, "invited@example.com")); res.Status != http.StatusOK {
+		t.Errorf("accepting the invitation: status %d: %s", res.Status, res.Body)
+	}
+}
+
+func TestResetKeepsTokenWhenUpdateFails(t *testing.T) {
+	env := testutil.Setup(t)
+	user := testutil.CreateUser(t, models.User{IsAmbassador: true})
+
+	res := env.Client(t).Do(http.MethodPost, "/api/forgot", map[string]string{"email": user.Email})
+	if res.Status != http.StatusOK {
+		t.Fatalf("forgot: status %d: %s", res.Status, res.Body)
+	}
+	token := emailedToken(t, env, user.Email)
+
+	// Make the password update fail after the token has been consumed
+	failUpdates := func(db *gorm.DB) {
+		if db.Statement.Table == "users" {
+			db.AddError(errors.New("disk full"))
+		}
+	}
+	if err := env.DB.Callback().Update().Before("gorm:update").Register("test:fail_users", failUpdates); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := reset(t, env, token); res.Status != http.StatusInternalServerError {
+		t.Fatalf("failing reset: status %d, want 500: %s", res.Status, res.Body)
+	}
+
+	if err := env.DB.Callback().Update().Remove("test:fail_users"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token was not used up by the failed attempt, but only works once
+	if res := reset(t, env, token); res.Status != http.StatusOK {
+		t.Fatalf("reset: status %d: %s", res.Status, res.Body)
+	}
+	if res := reset(t, env, token); res.Status != http.StatusBadRequest {
+		t.Errorf("reusing the token: status %d, want 400", res.Status)
+	}
+}
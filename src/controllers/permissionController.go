@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// PermissionRequest is the body accepted by CreatePermission and UpdatePermission
+type PermissionRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// AllPermissions returns every permission
+// URL: GET /api/admin/permissions
+func AllPermissions(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	var permissions []models.Permission
+
+	if err := database.DB.Find(&permissions).Error; err != nil {
+		return err
+	}
+
+	return c.JSON(permissions)
+}
+
+// CreatePermission creates a new named permission
+// URL: POST /api/admin/permissions
+func CreatePermission(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	var request PermissionRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	permission := models.Permission{
+		Name: request.Name,
+	}
+
+	if err := database.DB.Create(&permission).Error; err != nil {
+		return apperrors.FromDB(err, "permission")
+	}
+
+	return c.JSON(permission)
+}
+
+// GetPermission returns a single permission
+// URL: GET /api/admin/permissions/:id
+func GetPermission(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the permission ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var permission models.Permission
+
+	if err := database.DB.First(&permission, id).Error; err != nil {
+		return apperrors.FromDB(err, "permission")
+	}
+
+	return c.JSON(permission)
+}
+
+// UpdatePermission renames a permission
+// URL: PUT /api/admin/permissions/:id
+func UpdatePermission(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the permission ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var request PermissionRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Load the existing permission so a missing ID is reported as 404
+	var permission models.Permission
+	if err := database.DB.First(&permission, id).Error; err != nil {
+		return apperrors.FromDB(err, "permission")
+	}
+
+	permission.Name = request.Name
+
+	if err := database.DB.Model(&permission).Update("name", permission.Name).Error; err != nil {
+		return apperrors.FromDB(err, "permission")
+	}
+
+	return c.JSON(permission)
+}
+
+// DeletePermission removes a permission and revokes it from every role
+// URL: DELETE /api/admin/permissions/:id
+func DeletePermission(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the permission ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	// Delete the permission and revoke it from all roles
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Permission{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// No deleted row means the permission did not exist
+		if result.RowsAffected == 0 {
+			return apperrors.NotFound("permission not found")
+		}
+
+		return tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -1,38 +1,67 @@
 package controllers
 
 import (
-	"golangProject/database"
-	"golangProject/models"
-	"strconv"
+	"cmp"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"math"
+	"slices"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+// ProductRequest is the body accepted by CreateProduct and UpdateProduct
+type ProductRequest struct {
+	Title       string  `json:"title" validate:"required,max=255"`
+	Description string  `json:"description" validate:"max=5000"`
+	Image       string  `json:"image" validate:"omitempty,url,max=255"`
+	Price       float64 `json:"price" validate:"required,gt=0"`
+}
+
 // AllProducts retrieves a paginated list of products from the database
 // This uses the generic Paginate function for consistent pagination
+// Query parameters: page, per_page, sort (title, price, optionally prefixed with "-"),
+// s (title/description search), filter[title], price_min and price_max
 func AllProducts(c fiber.Ctx) error {
-	// Extract the page number from query parameters, default to page 1 if not provided
-	page, _ := strconv.Atoi(c.Query("page", "1"))
+	if err := middlewares.IsAuthorized(c, "products"); err != nil {
+		return err
+	}
 
-	// Use the generic Paginate function with Product entity
+	// Use the generic Paginate function with the Product model
 	// This provides standardized pagination response format
-	return c.JSON(models.Paginate(database.DB, &models.Product{}, page))
+	return paginate[models.Product](c, database.DB, models.ProductPageOptions)
 }
 
 // CreateProduct creates a new product in the database
 // This function allows adding new products to the catalog
 func CreateProduct(c fiber.Ctx) error {
-	// Create a Product struct to hold the request data
-	var product models.Product
+	if err := middlewares.IsAuthorized(c, "products"); err != nil {
+		return err
+	}
 
-	// Parse the JSON request body into the product struct
-	if err := c.Bind().Body(&product); err != nil {
+	var request ProductRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
+	// Create a Product struct from the validated request
+	product := models.Product{
+		Title:       request.Title,
+		Description: request.Description,
+		Image:       request.Image,
+		Price:       request.Price,
+	}
+
 	// Create the new product record in the database
 	// This executes: INSERT INTO products (title, description, image, price) VALUES (?, ?, ?, ?);
-	database.DB.Create(&product)
+	if err := database.DB.Create(&product).Error; err != nil {
+		return apperrors.FromDB(err, "product")
+	}
 
 	// Return the created product as JSON response
 	return c.JSON(product)
@@ -40,19 +69,25 @@ func CreateProduct(c fiber.Ctx) error {
 
 // GetProduct retrieves a specific product by ID from the database
 // This is used for viewing individual product details
-// URL: GET /api/products/:id
+// URL: GET /api/admin/products/:id
 func GetProduct(c fiber.Ctx) error {
-	// Extract the product ID from the URL parameter and convert to integer
-	id, _ := strconv.Atoi(c.Params("id"))
+	if err := middlewares.IsAuthorized(c, "products"); err != nil {
+		return err
+	}
 
-	// Create a Product instance with the ID set for the database query
-	product := models.Product{
-		Id: uint(id),
+	// Extract the product ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
 	}
 
+	var product models.Product
+
 	// Find the product in the database by primary key (ID)
-	// This executes: SELECT * FROM products WHERE id = ?;
-	database.DB.Find(&product)
+	// This executes: SELECT * FROM products WHERE id = ? LIMIT 1;
+	if err := database.DB.First(&product, id).Error; err != nil {
+		return apperrors.FromDB(err, "product")
+	}
 
 	// Return the product as JSON response
 	return c.JSON(product)
@@ -60,25 +95,46 @@ func GetProduct(c fiber.Ctx) error {
 
 // UpdateProduct updates an existing product's information
 // This allows modifying product details like title, description, image, and price
-// URL: PUT /api/products/:id
+// URL: PUT /api/admin/products/:id
 func UpdateProduct(c fiber.Ctx) error {
-	// Extract the product ID from the URL parameter and convert to integer
-	id, _ := strconv.Atoi(c.Params("id"))
+	if err := middlewares.IsAuthorized(c, "products"); err != nil {
+		return err
+	}
 
-	// Create a Product instance with the target ID
-	product := models.Product{
-		Id: uint(id),
+	// Extract the product ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
 	}
 
-	// Parse the JSON request body into the product struct
-	// This binds the updated fields to the product object
-	if err := c.Bind().Body(&product); err != nil {
+	var request ProductRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
+	// Load the existing product so a missing ID is reported as 404
+	// MySQL counts only changed rows, so RowsAffected cannot tell us this
+	var product models.Product
+	if err := database.DB.First(&product, id).Error; err != nil {
+		return apperrors.FromDB(err, "product")
+	}
+
+	// Apply the updated fields
+	product.Title = request.Title
+	product.Description = request.Description
+	product.Image = request.Image
+	product.Price = request.Price
+
 	// Update the product record in the database
 	// This executes: UPDATE products SET title=?, description=?, image=?, price=? WHERE id=?;
-	database.DB.Model(&product).Updates(product)
+	err = database.DB.Model(&product).
+		Select("title", "description", "image", "price").
+		Updates(product).Error
+	if err != nil {
+		return apperrors.FromDB(err, "product")
+	}
 
 	// Return the updated product as JSON response
 	return c.JSON(product)
@@ -86,20 +142,105 @@ func UpdateProduct(c fiber.Ctx) error {
 
 // DeleteProduct removes a product from the database
 // This is a destructive operation and should be protected with proper authorization
-// URL: DELETE /api/products/:id
+// URL: DELETE /api/admin/products/:id
 func DeleteProduct(c fiber.Ctx) error {
-	// Extract the product ID from the URL parameter and convert to integer
-	id, _ := strconv.Atoi(c.Params("id"))
+	if err := middlewares.IsAuthorized(c, "products"); err != nil {
+		return err
+	}
 
-	// Create a Product instance with the target ID for deletion
-	product := models.Product{
-		Id: uint(id),
+	// Extract the product ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
 	}
 
 	// Delete the product record from the database
 	// This executes: DELETE FROM products WHERE id=?;
-	database.DB.Delete(&product)
+	result := database.DB.Delete(&models.Product{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// No deleted row means the product did not exist
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("product not found")
+	}
 
 	// Return success status (204 No Content)
-	return nil
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ProductsFrontend returns the whole product catalogue for ambassadors
+// The catalogue is served from the cache and reloaded from MySQL after a change
+// URL: GET /api/ambassador/products/frontend
+func ProductsFrontend(c fiber.Ctx) error {
+	products, err := database.CachedProducts(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(products)
+}
+
+// ProductsBackend searches, sorts and pages the cached product catalogue
+// Query parameters: s (title/description search), sort (price or -price),
+// page and per_page
+// URL: GET /api/ambassador/products/backend
+func ProductsBackend(c fiber.Ctx) error {
+	request, err := models.NewPageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if request.Sort != "" && request.Sort != "price" && request.Sort != "-price" {
+		return apperrors.BadRequest("cannot sort by " + strings.TrimPrefix(request.Sort, "-"))
+	}
+
+	cached, err := database.CachedProducts(c.Context())
+	if err != nil {
+		return err
+	}
+
+	// Concurrent cache misses share the loaded slice, so work on a copy
+	products := slices.Clone(cached)
+
+	// Keep the products whose title or description contains the search term
+	if term := strings.ToLower(strings.TrimSpace(c.Query("s"))); term != "" {
+		matches := make([]models.Product, 0, len(products))
+		for _, product := range products {
+			if strings.Contains(strings.ToLower(product.Title), term) ||
+				strings.Contains(strings.ToLower(product.Description), term) {
+				matches = append(matches, product)
+			}
+		}
+		products = matches
+	}
+
+	// Sort by price; the stable sort keeps equal prices in catalogue order
+	if request.Sort != "" {
+		desc := request.Sort == "-price"
+		slices.SortStableFunc(products, func(a, b models.Product) int {
+			if desc {
+				return cmp.Compare(b.Price, a.Price)
+			}
+			return cmp.Compare(a.Price, b.Price)
+		})
+	}
+
+	// Cut out the requested page
+	total := int64(len(products))
+	lastPage := int(math.Ceil(float64(total) / float64(request.PerPage)))
+
+	start := min((request.Page-1)*request.PerPage, len(products))
+	end := min(start+request.PerPage, len(products))
+
+	return c.JSON(models.Page[models.Product]{
+		Data: products[start:end],
+		Meta: models.PageMeta{
+			Total:    &total,
+			Page:     request.Page,
+			PerPage:  request.PerPage,
+			LastPage: &lastPage,
+		},
+	})
 }
@@ -0,0 +1,217 @@
+package controllers_test
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestProductsPageBounds(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	createProducts(t, env, "Mug")
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"page=1", http.StatusOK},
+		{fmt.Sprintf("page=%d", models.MaxPage), http.StatusOK},
+		{fmt.Sprintf("page=%d", models.MaxPage+1), http.StatusUnprocessableEntity},
+		{"page=9223372036854775807", http.StatusUnprocessableEntity},
+		{"page=0", http.StatusBadRequest},
+		{"page=abc", http.StatusBadRequest},
+		{"page=99999999999999999999", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res := admin.Do(http.MethodGet, "/api/admin/products?"+tt.query, nil)
+			if res.Status != tt.status {
+				t.Fatalf("status %d, want %d: %s", res.Status, tt.status, res.Body)
+			}
+
+			if tt.status == http.StatusUnprocessableEntity {
+				var body errorResponse
+				res.Decode(t, &body)
+				if len(body.Details) != 1 || body.Details[0].Field != "page" {
+					t.Errorf("details %+v: want an error on page", body.Details)
+				}
+			}
+		})
+	}
+}
+
+// backendPrices returns the prices of the backend listing sorted by sort
+func backendPrices(t *testing.T, client *testutil.Client, sort string) []float64 {
+	res := client.Do(http.MethodGet, "/api/ambassador/products/backend?per_page=100&sort="+sort, nil)
+	if res.Status != http.StatusOK {
+		t.Errorf("sort %s: status %d: %s", sort, res.Status, res.Body)
+		return nil
+	}
+
+	var page models.Page[models.Product]
+	res.Decode(t, &page)
+
+	prices := make([]float64, 0, len(page.Data))
+	for _, product := range page.Data {
+		prices = append(prices, product.Price)
+	}
+
+	return prices
+}
+
+func TestProductsBackendSortsACopy(t *testing.T) {
+	env := testutil.Setup(t)
+	createProducts(t, env, "Mug", "Shirt", "Hat", "Pen", "Cap", "Bag")
+
+	// Each goroutine gets its own client, the cookie jar is not shared safely
+	const workers = 8
+	clients := make([]*testutil.Client, workers)
+	for i := range clients {
+		clients[i] = env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+	}
+
+	for round := 0; round < 5; round++ {
+		// Start from a cold cache so the concurrent misses share one loaded slice
+		if err := cache.Forget(context.Background(), database.ProductsCacheKey); err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		for i, client := range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				sort, ordered := "price", slices.IsSorted[[]float64]
+				if i%2 == 1 {
+					sort = "-price"
+					ordered = func(prices []float64) bool {
+						return slices.IsSortedFunc(prices, func(a, b float64) int { return cmp.Compare(b, a) })
+					}
+				}
+
+				if prices := backendPrices(t, client, sort); len(prices) != 6 || !ordered(prices) {
+					t.Errorf("sort %s: prices %v", sort, prices)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// The catalogue itself keeps its ID order
+	res := clients[0].Do(http.MethodGet, "/api/ambassador/products/frontend", nil)
+	var products []models.Product
+	res.Decode(t, &products)
+
+	if !slices.IsSortedFunc(products, func(a, b models.Product) int { return cmp.Compare(a.Id, b.Id) }) {
+		t.Errorf("catalogue reordered: %+v", products)
+	}
+}
+
+// listedTitles returns the titles on the admin product page selected by query
+// and the total of the filtered listing
+func listedTitles(t *testing.T, client *testutil.Client, query string) ([]string, int64) {
+	t.Helper()
+
+	res := client.Do(http.MethodGet, "/api/admin/products?sort=price&"+query, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", query, res.Status, res.Body)
+	}
+
+	var page models.Page[models.Product]
+	res.Decode(t, &page)
+
+	titles := make([]string, 0, len(page.Data))
+	for _, product := range page.Data {
+		titles = append(titles, product.Title)
+	}
+
+	return titles, *page.Meta.Total
+}
+
+func TestProductsFilter(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	// Priced 10, 20, 30 and 40
+	createProducts(t, env, "Mug", "Shirt", "Mug 50%", "Cap")
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"s=MUG", []string{"Mug", "Mug 50%"}},
+		// Wildcards in the search term match literally
+		{"s=%25", []string{"Mug 50%"}},
+		{"s=_", nil},
+		{"filter[title]=Mug", []string{"Mug"}},
+		{"filter[title]=Mug,Cap", []string{"Mug", "Cap"}},
+		{"filter[title]=", []string{"Mug", "Shirt", "Mug 50%", "Cap"}},
+		// Both bounds are inclusive
+		{"price_min=20", []string{"Shirt", "Mug 50%", "Cap"}},
+		{"price_max=20", []string{"Mug", "Shirt"}},
+		{"price_min=15&price_max=30", []string{"Shirt", "Mug 50%"}},
+		{"price_min=30&price_max=10", nil},
+		{"price_min=10.5", []string{"Shirt", "Mug 50%", "Cap"}},
+		{"s=mug&price_min=15", []string{"Mug 50%"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			titles, total := listedTitles(t, admin, tt.query)
+			if !slices.Equal(titles, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("titles %q of %d, want %q", titles, total, tt.want)
+			}
+		})
+	}
+
+	// The totals count the filtered rows, not the page
+	if titles, total := listedTitles(t, admin, "price_min=20&per_page=1"); !slices.Equal(titles, []string{"Shirt"}) || total != 3 {
+		t.Errorf("first page %q of %d, want [Shirt] of 3", titles, total)
+	}
+}
+
+func TestProductsFilterRejectsUnknownParameters(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	createProducts(t, env, "Mug")
+
+	tests := []struct {
+		query   string
+		message string
+	}{
+		// Only whitelisted columns can be filtered, even searchable ones
+		{"filter[price]=10", "cannot filter by price"},
+		{"filter[description]=x", "cannot filter by description"},
+		{"filter[id) OR (1]=1", "cannot filter by id) OR (1"},
+		{"price_min=abc", "invalid price_min: expected a number"},
+		{"price_max=1e", "invalid price_max: expected a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			res := admin.Do(http.MethodGet, "/api/admin/products?"+url.PathEscape(tt.query), nil)
+			if res.Status != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", res.Status, res.Body)
+			}
+
+			var body errorResponse
+			res.Decode(t, &body)
+			if body.Message != tt.message {
+				t.Errorf("message %q, want %q", body.Message, tt.message)
+			}
+		})
+	}
+}
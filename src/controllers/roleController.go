@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// RoleRequest is the body accepted by CreateRole and UpdateRole
+// Permissions holds the IDs of the permissions granted to the role
+type RoleRequest struct {
+	Name        string `json:"name" validate:"required,max=64"`
+	Permissions []uint `json:"permissions"`
+}
+
+// AllRoles returns every role with its permissions
+// URL: GET /api/admin/roles
+func AllRoles(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	var roles []models.Role
+
+	// Load all roles together with their permissions
+	if err := database.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return err
+	}
+
+	return c.JSON(roles)
+}
+
+// CreateRole creates a role granting the given permissions
+// URL: POST /api/admin/roles
+func CreateRole(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	var request RoleRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	permissions, err := findPermissions(request.Permissions)
+	if err != nil {
+		return err
+	}
+
+	role := models.Role{
+		Name:        request.Name,
+		Permissions: permissions,
+	}
+
+	// Insert the role and its role_permissions rows
+	if err := database.DB.Create(&role).Error; err != nil {
+		return apperrors.FromDB(err, "role")
+	}
+
+	return c.JSON(role)
+}
+
+// GetRole returns a single role with its permissions
+// URL: GET /api/admin/roles/:id
+func GetRole(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the role ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var role models.Role
+
+	if err := database.DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		return apperrors.FromDB(err, "role")
+	}
+
+	return c.JSON(role)
+}
+
+// UpdateRole renames a role and replaces its permissions
+// URL: PUT /api/admin/roles/:id
+func UpdateRole(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the role ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var request RoleRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// Load the existing role so a missing ID is reported as 404
+	var role models.Role
+	if err := database.DB.First(&role, id).Error; err != nil {
+		return apperrors.FromDB(err, "role")
+	}
+
+	permissions, err := findPermissions(request.Permissions)
+	if err != nil {
+		return err
+	}
+
+	role.Name = request.Name
+
+	// Update the name, then swap the role_permissions rows for the new set
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&role).Update("name", role.Name).Error; err != nil {
+			return apperrors.FromDB(err, "role")
+		}
+
+		return tx.Model(&role).Association("Permissions").Replace(permissions)
+	})
+	if err != nil {
+		return err
+	}
+
+	role.Permissions = permissions
+
+	return c.JSON(role)
+}
+
+// DeleteRole removes a role and its permission assignments
+// URL: DELETE /api/admin/roles/:id
+func DeleteRole(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "roles"); err != nil {
+		return err
+	}
+
+	// Extract the role ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	// Remove the role itself, then its role_permissions rows
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.Role{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// No deleted row means the role did not exist
+		if result.RowsAffected == 0 {
+			return apperrors.NotFound("role not found")
+		}
+
+		return tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// findPermissions loads the permissions with the given IDs
+// Returns a 422 error listing every ID that does not exist, so a role is never
+// saved with fewer permissions than requested
+func findPermissions(ids []uint) ([]models.Permission, error) {
+	var permissions []models.Permission
+
+	if len(ids) == 0 {
+		return permissions, nil
+	}
+
+	if err := database.DB.Where("id IN ?", ids).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[uint]bool, len(permissions))
+	for _, permission := range permissions {
+		found[permission.Id] = true
+	}
+
+	var unknown []string
+	for _, id := range ids {
+		if !found[id] {
+			unknown = append(unknown, strconv.Itoa(int(id)))
+			// Report duplicates once
+			found[id] = true
+		}
+	}
+
+	if len(unknown) > 0 {
+		return nil, apperrors.Validation([]util.FieldError{{
+			Field:   "permissions",
+			Message: "unknown permission IDs: " + strings.Join(unknown, ", "),
+		}})
+	}
+
+	return permissions, nil
+}
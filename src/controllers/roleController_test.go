@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"fmt"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// errorResponse is the JSON envelope rendered for errors
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Details []struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+	} `json:"details"`
+}
+
+func TestRolesRejectUnknownPermissions(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	var permission models.Permission
+	if err := env.DB.First(&permission).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	res := admin.Do(http.MethodPost, "/api/admin/roles", map[string]interface{}{
+		"name":        "Auditor",
+		"permissions": []uint{permission.Id, 998, 999, 998},
+	})
+	if res.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("create: status %d, want 422: %s", res.Status, res.Body)
+	}
+
+	var body errorResponse
+	res.Decode(t, &body)
+	if len(body.Details) != 1 || body.Details[0].Field != "permissions" || !strings.HasSuffix(body.Details[0].Message, "998, 999") {
+		t.Errorf("details %+v: want the unknown IDs 998, 999 on permissions", body.Details)
+	}
+
+	var count int64
+	env.DB.Model(&models.Role{}).Where("name = ?", "Auditor").Count(&count)
+	if count != 0 {
+		t.Error("role created despite unknown permissions")
+	}
+
+	res = admin.Do(http.MethodPost, "/api/admin/roles", map[string]interface{}{
+		"name":        "Auditor",
+		"permissions": []uint{permission.Id},
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("create: status %d: %s", res.Status, res.Body)
+	}
+
+	var role models.Role
+	res.Decode(t, &role)
+
+	// An update with an unknown ID keeps the existing permissions
+	res = admin.Do(http.MethodPut, fmt.Sprintf("/api/admin/roles/%d", role.Id), map[string]interface{}{
+		"name":        "Renamed",
+		"permissions": []uint{999},
+	})
+	if res.Status != http.StatusUnprocessableEntity {
+		t.Fatalf("update: status %d, want 422: %s", res.Status, res.Body)
+	}
+
+	var stored models.Role
+	if err := env.DB.Preload("Permissions").First(&stored, role.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Name != "Auditor" || len(stored.Permissions) != 1 {
+		t.Errorf("role %+v changed by a rejected update", stored)
+	}
+}
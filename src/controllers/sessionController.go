@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"errors"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// sessionFamilyLength is the number of random characters in a session family ID
+const sessionFamilyLength = 32
+
+// Refresh exchanges the refresh token cookie for a new access and refresh token
+// The presented refresh token is rotated and can never be used again; presenting
+// it a second time revokes every token of its session family
+// URL: POST /api/refresh
+func Refresh(c fiber.Ctx) error {
+	token := c.Cookies(util.RefreshCookieName)
+
+	var session models.Session
+	err := database.DB.Where("token_hash = ?", util.HashToken(token)).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return refreshUnauthorized(c)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	// A token that was already rotated is being replayed: assume it was stolen
+	if session.RotatedAt != nil {
+		if err := revokeSessionFamily(session.FamilyId); err != nil {
+			return err
+		}
+		return refreshUnauthorized(c)
+	}
+
+	if !session.Active(now) {
+		return refreshUnauthorized(c)
+	}
+
+	// Mark the token as used; the condition makes concurrent refreshes with the
+	// same token count as reuse instead of both succeeding
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND rotated_at IS NULL", session.Id).
+		Update("rotated_at", now)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if err := revokeSessionFamily(session.FamilyId); err != nil {
+			return err
+		}
+		return refreshUnauthorized(c)
+	}
+
+	if err := issueSessionTokens(c, session.UserId, session.Scope, session.FamilyId); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "success refresh",
+	})
+}
+
+// RevokeUserSessions logs a user out everywhere by revoking all of their sessions
+// URL: DELETE /api/admin/users/:id/sessions
+func RevokeUserSessions(c fiber.Ctx) error {
+	if err := middlewares.IsAuthorized(c, "users"); err != nil {
+		return err
+	}
+
+	// Extract the user ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	// The user must exist, otherwise revoking nothing would look like success
+	if err := database.DB.First(&models.User{}, id).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	result := database.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return c.JSON(fiber.Map{
+		"revoked": result.RowsAffected,
+	})
+}
+
+// startSession begins a new session family for the user and sets its cookies
+func startSession(c fiber.Ctx, userId uint, scope string) error {
+	familyId, err := util.RandomCode(sessionFamilyLength)
+	if err != nil {
+		return err
+	}
+
+	return issueSessionTokens(c, userId, scope, familyId)
+}
+
+// issueSessionTokens stores a new hashed refresh token in the family and sets
+// the access token and refresh token cookies
+func issueSessionTokens(c fiber.Ctx, userId uint, scope string, familyId string) error {
+	refreshToken, err := util.RandomToken()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	session := models.Session{
+		UserId:    userId,
+		FamilyId:  familyId,
+		Scope:     scope,
+		TokenHash: util.HashToken(refreshToken),
+		ExpiresAt: now.Add(util.RefreshTokenTTL()),
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		return err
+	}
+
+	accessToken, err := util.GenerateJWT(strconv.Itoa(int(userId)), scope, familyId)
+	if err != nil {
+		return err
+	}
+
+	c.Cookie(util.AuthCookie(accessToken, now.Add(util.AccessTokenTTL())))
+	c.Cookie(util.RefreshCookie(refreshToken, session.ExpiresAt))
+
+	return nil
+}
+
+// revokeSessionFamily revokes every refresh token of a session family, which also
+// invalidates the access tokens carrying its ID
+func revokeSessionFamily(familyId string) error {
+	return database.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// clearSessionCookies expires the access and refresh token cookies
+func clearSessionCookies(c fiber.Ctx) {
+	expired := time.Now().Add(-time.Hour)
+
+	c.Cookie(util.AuthCookie("", expired))
+	c.Cookie(util.RefreshCookie("", expired))
+}
+
+// refreshUnauthorized clears the session cookies and returns a 401 error
+func refreshUnauthorized(c fiber.Ctx) error {
+	clearSessionCookies(c)
+
+	return apperrors.Unauthorized("unauthorized")
+}
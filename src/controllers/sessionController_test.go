@@ -0,0 +1,155 @@
+package controllers_test
+
+import (
+	"fmt"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"testing"
+)
+
+// login signs the user in through the login endpoint of the API group, so the
+// client holds both the access and the refresh token
+func login(t *testing.T, env *testutil.Env, user models.User, group string) *testutil.Client {
+	t.Helper()
+
+	client := env.Client(t)
+	res := client.Do(http.MethodPost, "/api/"+group+"/login", map[string]string{"email": user.Email, "password": testutil.Password})
+	if res.Status != http.StatusOK {
+		t.Fatalf("login: status %d: %s", res.Status, res.Body)
+	}
+
+	return client
+}
+
+// replay returns a client holding the given cookies, like an attacker who
+// copied them
+func replay(t *testing.T, env *testutil.Env, access string, refresh string) *testutil.Client {
+	client := env.Client(t)
+	client.SetCookie(util.AuthCookieName, access)
+	client.SetCookie(util.RefreshCookieName, refresh)
+
+	return client
+}
+
+// checkStatus fails the test when the request does not return want
+func checkStatus(t *testing.T, client *testutil.Client, method string, path string, want int) {
+	t.Helper()
+
+	if res := client.Do(method, path, nil); res.Status != want {
+		t.Errorf("%s %s: status %d, want %d: %s", method, path, res.Status, want, res.Body)
+	}
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	env := testutil.Setup(t)
+	client := login(t, env, verifiedAmbassador(t), "ambassador")
+
+	access, refresh := client.Cookie(util.AuthCookieName), client.Cookie(util.RefreshCookieName)
+	if access == "" || refresh == "" {
+		t.Fatalf("login set access %q and refresh %q", access, refresh)
+	}
+
+	checkStatus(t, client, http.MethodPost, "/api/refresh", http.StatusOK)
+
+	if client.Cookie(util.RefreshCookieName) == refresh {
+		t.Error("refresh token was not rotated")
+	}
+	if client.Cookie(util.AuthCookieName) == "" {
+		t.Error("no new access token")
+	}
+
+	// The new tokens keep the scope of the login
+	checkStatus(t, client, http.MethodGet, "/api/ambassador/user", http.StatusOK)
+	checkStatus(t, client, http.MethodGet, "/api/admin/user", http.StatusUnauthorized)
+
+	// And can be rotated again
+	checkStatus(t, client, http.MethodPost, "/api/refresh", http.StatusOK)
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	env := testutil.Setup(t)
+	user := verifiedAmbassador(t)
+	client := login(t, env, user, "ambassador")
+	other := login(t, env, user, "ambassador")
+
+	stolen := replay(t, env, client.Cookie(util.AuthCookieName), client.Cookie(util.RefreshCookieName))
+
+	// The owner rotates first, then the copied token is presented again
+	checkStatus(t, client, http.MethodPost, "/api/refresh", http.StatusOK)
+	checkStatus(t, stolen, http.MethodPost, "/api/refresh", http.StatusUnauthorized)
+
+	// Reuse revoked the whole family: the rotated refresh token and the access
+	// tokens issued to it stop working
+	checkStatus(t, client, http.MethodPost, "/api/refresh", http.StatusUnauthorized)
+	checkStatus(t, replay(t, env, client.Cookie(util.AuthCookieName), ""), http.MethodGet, "/api/ambassador/user", http.StatusUnauthorized)
+	checkStatus(t, stolen, http.MethodGet, "/api/ambassador/user", http.StatusUnauthorized)
+
+	// Other sessions of the same user are not affected
+	checkStatus(t, other, http.MethodGet, "/api/ambassador/user", http.StatusOK)
+}
+
+func TestRefreshRejectsUnknownToken(t *testing.T) {
+	env := testutil.Setup(t)
+
+	checkStatus(t, env.Client(t), http.MethodPost, "/api/refresh", http.StatusUnauthorized)
+	checkStatus(t, replay(t, env, "", "not-a-token"), http.MethodPost, "/api/refresh", http.StatusUnauthorized)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	env := testutil.Setup(t)
+	client := login(t, env, testutil.CreateUser(t, models.User{RoleId: 1}), "admin")
+
+	copied := replay(t, env, client.Cookie(util.AuthCookieName), client.Cookie(util.RefreshCookieName))
+
+	checkStatus(t, client, http.MethodPost, "/api/admin/logout", http.StatusOK)
+
+	if client.Cookie(util.AuthCookieName) != "" || client.Cookie(util.RefreshCookieName) != "" {
+		t.Error("logout kept the session cookies")
+	}
+
+	// Tokens copied before the logout are revoked server-side
+	checkStatus(t, copied, http.MethodGet, "/api/admin/user", http.StatusUnauthorized)
+	checkStatus(t, copied, http.MethodPost, "/api/refresh", http.StatusUnauthorized)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	viewer := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 3}), util.ScopeAdmin)
+
+	user := verifiedAmbassador(t)
+	phone := login(t, env, user, "ambassador")
+	laptop := login(t, env, user, "ambassador")
+	bystander := login(t, env, verifiedAmbassador(t), "ambassador")
+
+	path := fmt.Sprintf("/api/admin/users/%d/sessions", user.Id)
+
+	// Revoking sessions needs edit_users
+	checkStatus(t, viewer, http.MethodDelete, path, http.StatusForbidden)
+	checkStatus(t, phone, http.MethodGet, "/api/ambassador/user", http.StatusOK)
+
+	res := admin.Do(http.MethodDelete, path, nil)
+	if res.Status != http.StatusOK {
+		t.Fatalf("status %d: %s", res.Status, res.Body)
+	}
+
+	var body struct {
+		Revoked int64 `json:"revoked"`
+	}
+	res.Decode(t, &body)
+	if body.Revoked != 2 {
+		t.Errorf("revoked %d sessions, want 2", body.Revoked)
+	}
+
+	for name, client := range map[string]*testutil.Client{"phone": phone, "laptop": laptop} {
+		checkStatus(t, client, http.MethodGet, "/api/ambassador/user", http.StatusUnauthorized)
+		if res := client.Do(http.MethodPost, "/api/refresh", nil); res.Status != http.StatusUnauthorized {
+			t.Errorf("%s refresh: status %d, want 401", name, res.Status)
+		}
+	}
+
+	checkStatus(t, bystander, http.MethodGet, "/api/ambassador/user", http.StatusOK)
+	checkStatus(t, admin, http.MethodDelete, "/api/admin/users/9999/sessions", http.StatusNotFound)
+}
@@ -1,54 +1,106 @@
 package controllers
 
 import (
-	"golangProject/database"
-	"golangProject/middlewares"
-	"golangProject/models"
-	"strconv"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"log"
 
 	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
 )
 
+// UserRequest is the body accepted by CreateUser and UpdateUser
+type UserRequest struct {
+	FirstName string `json:"first_name" validate:"required,max=255"`
+	LastName  string `json:"last_name" validate:"required,max=255"`
+	Email     string `json:"email" validate:"required,email,max=255"`
+	RoleId    uint   `json:"role_id" validate:"required"`
+}
+
 // AllUsers retrieves a paginated list of users from the database
 // This uses the generic Paginate function for consistent pagination
-// Security Note: In production, consider adding role-based access control
+// Query parameters: page, per_page, sort (first_name, last_name, email),
+// s (name/email search), filter[email] and filter[role_id]
 func AllUsers(c fiber.Ctx) error {
 	if err := middlewares.IsAuthorized(c, "users"); err != nil {
 		return err
 	}
 
-	// Extract the page number from query parameters, default to page 1 if not provided
-	page, _ := strconv.Atoi(c.Query("page", "1"))
-
-	// Use the generic Paginate function with User entity
+	// Use the generic Paginate function with the User model
 	// This provides standardized pagination response format
-	return c.JSON(models.Paginate(database.DB, &models.User{}, page))
+	return paginate[models.User](c, database.DB, models.UserPageOptions)
 }
 
-// CreateUser creates a new user with a default password
-// This function allows creating users programmatically (admin function)
-// In production, consider adding validation and proper error handling
+// CreateUser creates a new user and emails them an invitation link
+// The account has no usable password until the user sets one through the link
 func CreateUser(c fiber.Ctx) error {
 	if err := middlewares.IsAuthorized(c, "users"); err != nil {
 		return err
 	}
 
-	// Create a User struct to hold the request data
-	var user models.User
+	var request UserRequest
 
-	// Parse the JSON request body into the user struct
-	if err := c.Bind().Body(&user); err != nil {
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
 		return err
 	}
 
-	// Set a default password using the User model's SetPassword method
-	// This encapsulates the password hashing logic within the model
-	// Note: "3" is a hardcoded default password - consider making this configurable
-	user.SetPassword("3")
+	// Each email can only be registered once
+	if err := checkEmailAvailable(request.Email, 0); err != nil {
+		return err
+	}
 
-	// Create the new user record in the database
-	// This executes: INSERT INTO users (...) VALUES (...);
-	database.DB.Create(&user)
+	// Create a User struct from the validated request
+	user := models.User{
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Email:     request.Email,
+		RoleId:    request.RoleId,
+	}
+
+	// Set a random password nobody knows, the user chooses their own via the invitation
+	placeholder, err := util.RandomToken()
+	if err != nil {
+		return err
+	}
+	user.SetPassword(placeholder)
+
+	// Create the user together with the invitation token; the email is sent
+	// once both are committed so a slow mail server does not hold the
+	// transaction open
+	var token string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// This executes: INSERT INTO users (...) VALUES (...);
+		if err := tx.Create(&user).Error; err != nil {
+			return apperrors.FromDB(err, "user")
+		}
+
+		var err error
+		token, err = issuePasswordToken(tx, user, models.PasswordTokenInvite)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	// Email the invitation link that lets the user set their password
+	// Without it nobody can sign in to the account, so it is removed again and
+	// the admin can retry without hitting a duplicate email
+	if err := emailPasswordToken(c, user, models.PasswordTokenInvite, token); err != nil {
+		if err := deleteUninvitedUser(user.Id); err != nil {
+			log.Println("failed to remove user after the invitation failed:", err)
+		}
+
+		return &apperrors.Error{
+			Code:    fiber.StatusBadGateway,
+			Message: "the invitation email could not be sent, the user was not created",
+			Err:     err,
+		}
+	}
 
 	// Return the created user as JSON response (password excluded)
 	return c.JSON(user)
@@ -56,24 +108,25 @@ func CreateUser(c fiber.Ctx) error {
 
 // GetUser retrieves a specific user by ID from the database
 // This is typically used for viewing individual user profiles
-// URL: GET /api/users/:id
+// URL: GET /api/admin/users/:id
 func GetUser(c fiber.Ctx) error {
 	if err := middlewares.IsAuthorized(c, "users"); err != nil {
 		return err
 	}
 
-	// Extract the user ID from the URL parameter and convert to integer
-	// c.Params("id") gets the ":id" value from the route
-	id, _ := strconv.Atoi(c.Params("id"))
-
-	// Create a User instance with the ID set for the database query
-	user := models.User{
-		Id: uint(id),
+	// Extract the user ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
 	}
 
+	var user models.User
+
 	// Find the user in the database by primary key (ID)
-	// This executes: SELECT * FROM users WHERE id = ?;
-	database.DB.Preload("Role").Find(&user)
+	// This executes: SELECT * FROM users WHERE id = ? LIMIT 1;
+	if err := database.DB.Preload("Role").First(&user, id).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
 
 	// Return the user as JSON response (password excluded due to json:"-")
 	return c.JSON(user)
@@ -81,30 +134,52 @@ func GetUser(c fiber.Ctx) error {
 
 // UpdateUser updates an existing user's information
 // This allows modifying user details like name and email
-// URL: PUT /api/users/:id
+// URL: PUT /api/admin/users/:id
 func UpdateUser(c fiber.Ctx) error {
 	if err := middlewares.IsAuthorized(c, "users"); err != nil {
 		return err
 	}
 
-	// Extract the user ID from the URL parameter and convert to integer
-	id, _ := strconv.Atoi(c.Params("id"))
+	// Extract the user ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
+	}
+
+	var request UserRequest
 
-	// Create a User instance with the target ID
-	user := models.User{
-		Id: uint(id),
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
 	}
 
-	// Parse the JSON request body into the user struct
-	// This binds the updated fields (first_name, last_name, email) to the user object
-	if err := c.Bind().Body(&user); err != nil {
+	// Load the existing user so a missing ID is reported as 404
+	// MySQL counts only changed rows, so RowsAffected cannot tell us this
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return apperrors.FromDB(err, "user")
+	}
+
+	// The new email must not belong to another account
+	if err := checkEmailAvailable(request.Email, id); err != nil {
 		return err
 	}
 
+	// Apply the updated fields
+	user.FirstName = request.FirstName
+	user.LastName = request.LastName
+	user.Email = request.Email
+	user.RoleId = request.RoleId
+
 	// Update the user record in the database
-	// .Model() specifies which record to update, .Updates() applies the changes
-	// This executes: UPDATE users SET first_name=?, last_name=?, email=? WHERE id=?;
-	database.DB.Model(&user).Updates(user)
+	// .Model() specifies which record to update, .Select() limits the written columns
+	// This executes: UPDATE users SET first_name=?, last_name=?, email=?, role_id=? WHERE id=?;
+	err = database.DB.Model(&user).
+		Select("first_name", "last_name", "email", "role_id").
+		Updates(user).Error
+	if err != nil {
+		return apperrors.FromDB(err, "user")
+	}
 
 	// Return the updated user as JSON response
 	return c.JSON(user)
@@ -112,25 +187,42 @@ func UpdateUser(c fiber.Ctx) error {
 
 // DeleteUser removes a user from the database
 // This is a destructive operation and should be protected with proper authorization
-// URL: DELETE /api/users/:id
+// URL: DELETE /api/admin/users/:id
 func DeleteUser(c fiber.Ctx) error {
 	if err := middlewares.IsAuthorized(c, "users"); err != nil {
 		return err
 	}
 
-	// Extract the user ID from the URL parameter and convert to integer
-	id, _ := strconv.Atoi(c.Params("id"))
-
-	// Create a User instance with the target ID for deletion
-	user := models.User{
-		Id: uint(id),
+	// Extract the user ID from the URL parameter, rejecting non-numeric values
+	id, err := paramId(c, "id")
+	if err != nil {
+		return err
 	}
 
 	// Delete the user record from the database
 	// This executes: DELETE FROM users WHERE id=?;
-	database.DB.Delete(&user)
+	result := database.DB.Delete(&models.User{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// No deleted row means the user did not exist
+	if result.RowsAffected == 0 {
+		return apperrors.NotFound("user not found")
+	}
+
+	// Return success status (204 No Content)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// deleteUninvitedUser removes a user created by CreateUser whose invitation
+// could not be sent, together with the invitation token
+func deleteUninvitedUser(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&models.PasswordToken{}).Error; err != nil {
+			return err
+		}
 
-	// Return nil (no content) to indicate successful deletion
-	// Alternatively, you could return a success message
-	return nil
+		return tx.Delete(&models.User{}, id).Error
+	})
 }
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// bindAndValidate parses the request body into request and checks its `validate` tags
+// Returns a 400 error for a malformed body and a 422 error listing every failing
+// field for an invalid one
+func bindAndValidate(c fiber.Ctx, request interface{}) error {
+	// Parse the JSON request body into the request struct
+	if err := c.Bind().Body(request); err != nil {
+		return apperrors.BadRequest("invalid request body")
+	}
+
+	// Check the declarative rules and list every failing field
+	if fields := util.Validate(request); fields != nil {
+		return apperrors.Validation(fields)
+	}
+
+	return nil
+}
+
+// paramId parses the named route parameter as a positive numeric ID
+// Returns a 400 error if the parameter is missing, not a number or zero
+func paramId(c fiber.Ctx, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Params(name), 10, 64)
+	if err != nil || id == 0 {
+		return 0, apperrors.BadRequest("invalid " + name)
+	}
+
+	return uint(id), nil
+}
+
+// paginate responds with the page of T selected by the page, per_page, sort
+// and cursor query parameters, narrowed by the model's search and filter
+// parameters; db may carry conditions that scope the listing
+func paginate[T any](c fiber.Ctx, db *gorm.DB, options models.PageOptions) error {
+	request, err := models.NewPageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	// Filters are applied before paginating so the totals count filtered rows
+	db, err = models.Filter(c, db, options.Filters)
+	if err != nil {
+		return err
+	}
+
+	page, err := models.Paginate[T](db, request, options)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
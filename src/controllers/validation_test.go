@@ -0,0 +1,245 @@
+package controllers_test
+
+import (
+	"fmt"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// resource is an admin CRUD endpoint under /api/admin
+type resource struct {
+	path string
+	// create inserts a row that the test may delete and returns its ID
+	create func(t *testing.T, db *gorm.DB) uint
+	// update is a valid body for PUT
+	update interface{}
+}
+
+var resources = []resource{
+	{
+		path: "/api/admin/products",
+		create: func(t *testing.T, db *gorm.DB) uint {
+			product := models.Product{Title: "Mug", Price: 10}
+			if err := db.Create(&product).Error; err != nil {
+				t.Fatal(err)
+			}
+			return product.Id
+		},
+		update: map[string]interface{}{"title": "Cup", "price": 12},
+	},
+	{
+		path: "/api/admin/users",
+		create: func(t *testing.T, db *gorm.DB) uint {
+			return testutil.CreateUser(t, models.User{RoleId: 3}).Id
+		},
+		update: map[string]interface{}{"first_name": "A", "last_name": "B", "email": "renamed@example.com", "role_id": 3},
+	},
+	{
+		path: "/api/admin/roles",
+		create: func(t *testing.T, db *gorm.DB) uint {
+			role := models.Role{Name: "Auditor"}
+			if err := db.Create(&role).Error; err != nil {
+				t.Fatal(err)
+			}
+			return role.Id
+		},
+		update: map[string]interface{}{"name": "Renamed", "permissions": []uint{}},
+	},
+	{
+		path: "/api/admin/permissions",
+		create: func(t *testing.T, db *gorm.DB) uint {
+			permission := models.Permission{Name: "view_reports"}
+			if err := db.Create(&permission).Error; err != nil {
+				t.Fatal(err)
+			}
+			return permission.Id
+		},
+		update: map[string]interface{}{"name": "edit_reports"},
+	},
+}
+
+func TestInvalidIdsAreRejected(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	for _, resource := range resources {
+		for _, id := range []string{"abc", "0", "-1", "1.5", "99999999999999999999"} {
+			t.Run(resource.path+"/"+id, func(t *testing.T) {
+				path := resource.path + "/" + id
+
+				if res := admin.Do(http.MethodGet, path, nil); res.Status != http.StatusBadRequest {
+					t.Errorf("GET: status %d, want 400: %s", res.Status, res.Body)
+				}
+				if res := admin.Do(http.MethodPut, path, resource.update); res.Status != http.StatusBadRequest {
+					t.Errorf("PUT: status %d, want 400: %s", res.Status, res.Body)
+				}
+				if res := admin.Do(http.MethodDelete, path, nil); res.Status != http.StatusBadRequest {
+					t.Errorf("DELETE: status %d, want 400: %s", res.Status, res.Body)
+				}
+			})
+		}
+	}
+
+	for _, path := range []string{"/api/admin/orders/abc/cancel", "/api/admin/orders/0/refund"} {
+		if res := admin.Do(http.MethodPost, path, map[string]float64{}); res.Status != http.StatusBadRequest {
+			t.Errorf("POST %s: status %d, want 400: %s", path, res.Status, res.Body)
+		}
+	}
+}
+
+func TestMissingRowsAreNotFound(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	for _, resource := range resources {
+		t.Run(resource.path, func(t *testing.T) {
+			path := resource.path + "/9999"
+
+			if res := admin.Do(http.MethodGet, path, nil); res.Status != http.StatusNotFound {
+				t.Errorf("GET: status %d, want 404: %s", res.Status, res.Body)
+			}
+			if res := admin.Do(http.MethodPut, path, resource.update); res.Status != http.StatusNotFound {
+				t.Errorf("PUT: status %d, want 404: %s", res.Status, res.Body)
+			}
+			if res := admin.Do(http.MethodDelete, path, nil); res.Status != http.StatusNotFound {
+				t.Errorf("DELETE: status %d, want 404: %s", res.Status, res.Body)
+			}
+		})
+	}
+
+	for _, path := range []string{"/api/admin/orders/9999/cancel", "/api/admin/orders/9999/refund"} {
+		if res := admin.Do(http.MethodPost, path, map[string]float64{}); res.Status != http.StatusNotFound {
+			t.Errorf("POST %s: status %d, want 404: %s", path, res.Status, res.Body)
+		}
+	}
+}
+
+func TestDeleteReturnsNoContent(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	for _, resource := range resources {
+		t.Run(resource.path, func(t *testing.T) {
+			path := fmt.Sprintf("%s/%d", resource.path, resource.create(t, env.DB))
+
+			res := admin.Do(http.MethodDelete, path, nil)
+			if res.Status != http.StatusNoContent {
+				t.Fatalf("DELETE: status %d, want 204: %s", res.Status, res.Body)
+			}
+			if len(res.Body) != 0 {
+				t.Errorf("DELETE: body %q, want none", res.Body)
+			}
+
+			// The row is gone, so a second delete has nothing to remove
+			if res := admin.Do(http.MethodGet, path, nil); res.Status != http.StatusNotFound {
+				t.Errorf("GET after delete: status %d, want 404", res.Status)
+			}
+			if res := admin.Do(http.MethodDelete, path, nil); res.Status != http.StatusNotFound {
+				t.Errorf("second DELETE: status %d, want 404", res.Status)
+			}
+		})
+	}
+}
+
+func TestInvalidBodiesAreUnprocessable(t *testing.T) {
+	env := testutil.Setup(t)
+	public := env.Client(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+	ambassador := env.LoginAs(t, verifiedAmbassador(t), util.ScopeAmbassador)
+
+	longPassword := strings.Repeat("x", 73)
+
+	tests := []struct {
+		name   string
+		client *testutil.Client
+		method string
+		path   string
+		body   interface{}
+		// fields are the failing fields, in the order they are reported
+		fields []string
+	}{
+		{"register empty", public, http.MethodPost, "/api/ambassador/register", map[string]string{},
+			[]string{"first_name", "last_name", "email", "password", "password_confirm"}},
+		{"register mismatch", public, http.MethodPost, "/api/ambassador/register",
+			map[string]string{"first_name": "A", "last_name": "B", "email": "a@example.com", "password": "password1", "password_confirm": "password2"},
+			[]string{"password_confirm"}},
+		{"register long password", public, http.MethodPost, "/api/admin/register",
+			map[string]string{"first_name": "A", "last_name": "B", "email": "a@example.com", "password": longPassword, "password_confirm": longPassword},
+			[]string{"password"}},
+		{"login", public, http.MethodPost, "/api/admin/login", map[string]string{"email": "nope"},
+			[]string{"email", "password"}},
+		{"update info", admin, http.MethodPut, "/api/admin/users/info", map[string]string{"first_name": "", "last_name": "B", "email": "nope"},
+			[]string{"first_name", "email"}},
+		{"update password", ambassador, http.MethodPut, "/api/ambassador/users/password", map[string]string{"password": "short", "password_confirm": "other"},
+			[]string{"password", "password_confirm"}},
+		{"create product", admin, http.MethodPost, "/api/admin/products", map[string]interface{}{"title": "", "image": "not a url", "price": 0},
+			[]string{"title", "image", "price"}},
+		{"create product negative price", admin, http.MethodPost, "/api/admin/products", map[string]interface{}{"title": "Mug", "price": -1},
+			[]string{"price"}},
+		{"create user", admin, http.MethodPost, "/api/admin/users", map[string]string{"email": "nope"},
+			[]string{"first_name", "last_name", "email", "role_id"}},
+		{"create link", ambassador, http.MethodPost, "/api/ambassador/links", map[string]interface{}{"products": []uint{}},
+			[]string{"products"}},
+		{"create order", public, http.MethodPost, "/api/checkout/orders",
+			map[string]interface{}{"email": "nope", "products": []map[string]uint{{"product_id": 1, "quantity": 0}, {"quantity": 1}}},
+			[]string{"code", "first_name", "last_name", "email", "products[0].quantity", "products[1].product_id"}},
+		{"create order without products", public, http.MethodPost, "/api/checkout/orders",
+			map[string]interface{}{"code": "abc", "first_name": "A", "last_name": "B", "email": "a@example.com", "products": []uint{}},
+			[]string{"products"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := tt.client.Do(tt.method, tt.path, tt.body)
+			if res.Status != http.StatusUnprocessableEntity {
+				t.Fatalf("status %d, want 422: %s", res.Status, res.Body)
+			}
+
+			var body errorResponse
+			res.Decode(t, &body)
+
+			if body.Code != http.StatusUnprocessableEntity || body.Message != "validation failed" {
+				t.Errorf("envelope code %d message %q", body.Code, body.Message)
+			}
+
+			fields := make([]string, len(body.Details))
+			for i, detail := range body.Details {
+				fields[i] = detail.Field
+				if detail.Message == "" {
+					t.Errorf("no message for %s", detail.Field)
+				}
+			}
+
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("fields %v, want %v", fields, tt.fields)
+			}
+		})
+	}
+
+	// Nothing invalid was stored
+	var products, links, orders int64
+	env.DB.Model(&models.Product{}).Count(&products)
+	env.DB.Model(&models.Link{}).Count(&links)
+	env.DB.Model(&models.Order{}).Count(&orders)
+	if products+links+orders != 0 {
+		t.Errorf("stored %d products, %d links and %d orders", products, links, orders)
+	}
+}
+
+func TestMalformedBodiesAreBadRequests(t *testing.T) {
+	env := testutil.Setup(t)
+	admin := env.LoginAs(t, testutil.CreateUser(t, models.User{RoleId: 1}), util.ScopeAdmin)
+
+	for _, body := range []interface{}{"not an object", []int{1, 2}, map[string]interface{}{"title": 5, "price": "ten"}} {
+		if res := admin.Do(http.MethodPost, "/api/admin/products", body); res.Status != http.StatusBadRequest {
+			t.Errorf("body %v: status %d, want 400: %s", body, res.Status, res.Body)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/mail"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+	"log"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// verificationTokenTTL is how long an email verification link stays valid
+const verificationTokenTTL = 24 * time.Hour
+
+// verificationResendInterval is the minimum time between two verification emails
+const verificationResendInterval = time.Minute
+
+// errInvalidVerificationToken is returned for bad, expired or outdated verification links
+var errInvalidVerificationToken = apperrors.BadRequest("invalid or expired token")
+
+// VerifyRequest is the body accepted by Verify
+type VerifyRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// Verify marks the user's email address as verified using the signed link token
+// URL: POST /api/verify
+func Verify(c fiber.Ctx) error {
+	var request VerifyRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// The signature proves we issued the token for this user and email
+	id, email, err := util.ParseVerificationJWT(request.Token)
+	if err != nil {
+		return errInvalidVerificationToken
+	}
+
+	// Only verify if the email has not changed since the link was sent
+	result := database.DB.Model(&models.User{}).
+		Where("id = ? AND email = ? AND email_verified_at IS NULL", id, email).
+		Update("email_verified_at", time.Now())
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		var user models.User
+		err := database.DB.Where("id = ? AND email = ?", id, email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidVerificationToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// Following the link twice is harmless
+		if !user.IsVerified() {
+			return errInvalidVerificationToken
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "success verify",
+	})
+}
+
+// ResendVerification emails a new verification link
+// Requests are limited to one per verificationResendInterval per account;
+// unknown or already verified emails get the same response as a successful send
+// URL: POST /api/verify/resend
+func ResendVerification(c fiber.Ctx) error {
+	var request EmailRequest
+
+	// Parse and validate the request body
+	if err := bindAndValidate(c, &request); err != nil {
+		return err
+	}
+
+	// A missing account is not an error, the response must not reveal it
+	var user models.User
+	err := database.DB.Where("email = ?", request.Email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	if user.Id != 0 && !user.IsVerified() {
+		// SET NX only succeeds if no email was sent within the interval
+		key := "verify:resend:" + strconv.Itoa(int(user.Id))
+		allowed, err := database.Cache.SetNX(c.Context(), key, 1, verificationResendInterval).Result()
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			return apperrors.TooManyRequests("please wait before requesting another verification email")
+		}
+
+		if err := sendVerificationEmail(c, user); err != nil {
+			log.Println("failed to send verification email:", err)
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "if the email is registered and unverified, a verification link has been sent",
+	})
+}
+
+// sendVerificationEmail emails the user a signed link to verify their address
+func sendVerificationEmail(c fiber.Ctx, user models.User) error {
+	token, err := util.GenerateVerificationJWT(strconv.Itoa(int(user.Id)), user.Email, verificationTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := mail.Link("/verify", url.Values{"token": {token}})
+
+	return mail.Send(c.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by following the link below. It expires in %s.\n\n%s\n",
+			user.FirstName, verificationTokenTTL, link),
+	})
+}
+
+// checkEmailAvailable returns a 409 error if another account already uses the email
+// exceptId excludes the account being updated; pass 0 for new accounts
+func checkEmailAvailable(email string, exceptId uint) error {
+	var count int64
+	err := database.DB.Model(&models.User{}).Where("email = ? AND id <> ?", email, exceptId).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return apperrors.Conflict("email already registered")
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package controllers_test
+
+import (
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"go-ambassador/src/util"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// verify follows the emailed verification link
+func verify(t *testing.T, env *testutil.Env, token string) *testutil.Response {
+	return env.Client(t).Do(http.MethodPost, "/api/verify", map[string]string{"token": token})
+}
+
+// isVerified reloads the user and reports whether their email is verified
+func isVerified(t *testing.T, env *testutil.Env, id uint) bool {
+	t.Helper()
+
+	var user models.User
+	if err := env.DB.First(&user, id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return user.IsVerified()
+}
+
+func TestVerifyUnlocksLinks(t *testing.T) {
+	env := testutil.Setup(t)
+	products := createProducts(t, env, "Mug")
+
+	client := env.Client(t)
+	res := client.Do(http.MethodPost, "/api/ambassador/register", map[string]string{
+		"first_name":       "New",
+		"last_name":        "Ambassador",
+		"email":            "new@example.com",
+		"password":         "password1",
+		"password_confirm": "password1",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("register: status %d: %s", res.Status, res.Body)
+	}
+
+	var user models.User
+	res.Decode(t, &user)
+
+	res = client.Do(http.MethodPost, "/api/ambassador/login", map[string]string{"email": "new@example.com", "password": "password1"})
+	if res.Status != http.StatusOK {
+		t.Fatalf("login: status %d: %s", res.Status, res.Body)
+	}
+
+	// Unverified ambassadors cannot create links
+	body := map[string]interface{}{"products": []uint{products[0].Id}}
+	if res := client.Do(http.MethodPost, "/api/ambassador/links", body); res.Status != http.StatusForbidden {
+		t.Fatalf("unverified: status %d, want 403: %s", res.Status, res.Body)
+	}
+
+	token := emailedToken(t, env, "new@example.com")
+	if res := verify(t, env, token); res.Status != http.StatusOK {
+		t.Fatalf("verify: status %d: %s", res.Status, res.Body)
+	}
+	if !isVerified(t, env, user.Id) {
+		t.Fatal("user not verified")
+	}
+
+	// Following the link again is harmless
+	if res := verify(t, env, token); res.Status != http.StatusOK {
+		t.Errorf("verifying twice: status %d: %s", res.Status, res.Body)
+	}
+
+	createLink(t, client, products...)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	env := testutil.Setup(t)
+	user := testutil.CreateUser(t, models.User{IsAmbassador: true})
+	id := strconv.Itoa(int(user.Id))
+
+	token := func(email string, ttl time.Duration) string {
+		token, err := util.GenerateVerificationJWT(id, email, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	// An access token is signed with the same secret but is not a verification token
+	access, err := util.GenerateJWT(id, util.ScopeAmbassador, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"malformed", "not-a-token"},
+		{"expired", token(user.Email, -time.Minute)},
+		{"for an old email", token("old@example.com", time.Hour)},
+		{"for an unknown user", func() string {
+			token, _ := util.GenerateVerificationJWT("9999", user.Email, time.Hour)
+			return token
+		}()},
+		{"access token", access},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := verify(t, env, tt.token); res.Status != http.StatusBadRequest {
+				t.Errorf("status %d, want 400: %s", res.Status, res.Body)
+			}
+		})
+	}
+
+	if isVerified(t, env, user.Id) {
+		t.Error("user verified by a rejected token")
+	}
+}
+
+func TestVerifyAfterEmailChange(t *testing.T) {
+	env := testutil.Setup(t)
+	user := verifiedAmbassador(t)
+	client := env.LoginAs(t, user, util.ScopeAmbassador)
+
+	// A link sent for the old address before the change
+	used, err := util.GenerateVerificationJWT(strconv.Itoa(int(user.Id)), user.Email, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := client.Do(http.MethodPut, "/api/ambassador/users/info", map[string]string{
+		"first_name": user.FirstName,
+		"last_name":  user.LastName,
+		"email":      "changed@example.com",
+	})
+	if res.Status != http.StatusOK {
+		t.Fatalf("update: status %d: %s", res.Status, res.Body)
+	}
+	if isVerified(t, env, user.Id) {
+		t.Fatal("changed email still verified")
+	}
+
+	if res := verify(t, env, used); res.Status != http.StatusBadRequest {
+		t.Errorf("old link: status %d, want 400", res.Status)
+	}
+
+	if res := verify(t, env, emailedToken(t, env, "changed@example.com")); res.Status != http.StatusOK {
+		t.Fatalf("new link: status %d: %s", res.Status, res.Body)
+	}
+	if !isVerified(t, env, user.Id) {
+		t.Error("changed email not verified")
+	}
+}
+
+func TestResendVerificationIsRateLimited(t *testing.T) {
+	env := testutil.Setup(t)
+	user := testutil.CreateUser(t, models.User{IsAmbassador: true})
+	client := env.Client(t)
+
+	resend := func() *testutil.Response {
+		return client.Do(http.MethodPost, "/api/verify/resend", map[string]string{"email": user.Email})
+	}
+
+	if res := resend(); res.Status != http.StatusOK {
+		t.Fatalf("first resend: status %d: %s", res.Status, res.Body)
+	}
+	if len(env.Mail.Messages()) != 1 {
+		t.Fatalf("%d emails sent, want 1", len(env.Mail.Messages()))
+	}
+
+	if res := resend(); res.Status != http.StatusTooManyRequests {
+		t.Errorf("second resend: status %d, want 429", res.Status)
+	}
+	if len(env.Mail.Messages()) != 1 {
+		t.Errorf("%d emails sent, want still 1", len(env.Mail.Messages()))
+	}
+
+	// The limit lifts after the interval
+	env.Redis.FastForward(time.Minute)
+	if res := resend(); res.Status != http.StatusOK {
+		t.Errorf("resend after the interval: status %d: %s", res.Status, res.Body)
+	}
+
+	// Unknown and verified addresses get the same answer without an email
+	sent := len(env.Mail.Messages())
+	for _, email := range []string{"nobody@example.com", verifiedAmbassador(t).Email} {
+		res := client.Do(http.MethodPost, "/api/verify/resend", map[string]string{"email": email})
+		if res.Status != http.StatusOK {
+			t.Errorf("%s: status %d", email, res.Status)
+		}
+	}
+	if len(env.Mail.Messages()) != sent {
+		t.Error("email sent to an unknown or verified address")
+	}
+}
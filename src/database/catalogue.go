@@ -0,0 +1,27 @@
+package database
+
+import (
+	"context"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/models"
+	"time"
+)
+
+// ProductsCacheKey holds the JSON encoded product catalogue
+const ProductsCacheKey = "products:catalogue"
+
+// productsCacheTTL bounds how stale the catalogue can get if an invalidation is missed
+const productsCacheTTL = 30 * time.Minute
+
+// CachedProducts returns every product, served from the cache when possible
+// On a miss the catalogue is loaded from MySQL and cached again; product writes
+// invalidate it through the models.ProductsCacheTag hooks
+// Callers that miss together share the returned slice and must not modify it
+func CachedProducts(ctx context.Context) ([]models.Product, error) {
+	return cache.Remember(ctx, ProductsCacheKey, productsCacheTTL, []string{models.ProductsCacheTag},
+		func(ctx context.Context) ([]models.Product, error) {
+			var products []models.Product
+			err := DB.WithContext(ctx).Order("id").Find(&products).Error
+			return products, err
+		})
+}
@@ -0,0 +1,85 @@
+package database_test
+
+import (
+	"context"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"slices"
+	"testing"
+)
+
+// catalogueTitles returns the titles of the cached catalogue
+func catalogueTitles(t *testing.T) []string {
+	t.Helper()
+
+	products, err := database.CachedProducts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	titles := make([]string, len(products))
+	for i, product := range products {
+		titles[i] = product.Title
+	}
+
+	return titles
+}
+
+func TestProductWritesInvalidateCatalogue(t *testing.T) {
+	db := testutil.DB(t)
+	server := testutil.Redis(t)
+
+	mug := models.Product{Title: "Mug", Price: 10}
+	if err := db.Create(&mug).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name  string
+		write func() error
+		want  []string
+	}{
+		{"create", func() error { return db.Create(&models.Product{Title: "Shirt", Price: 20}).Error }, []string{"Mug", "Shirt"}},
+		{"update", func() error { return db.Model(&mug).Update("title", "Cup").Error }, []string{"Cup", "Shirt"}},
+		{"save", func() error { mug.Title = "Tumbler"; return db.Save(&mug).Error }, []string{"Tumbler", "Shirt"}},
+		{"delete", func() error { return db.Delete(&mug).Error }, []string{"Shirt"}},
+	}
+
+	for _, step := range steps {
+		// Fill the cache with the catalogue before the write
+		catalogueTitles(t)
+		if !server.Exists(database.ProductsCacheKey) {
+			t.Fatalf("%s: catalogue not cached", step.name)
+		}
+
+		if err := step.write(); err != nil {
+			t.Fatal(err)
+		}
+		if server.Exists(database.ProductsCacheKey) {
+			t.Errorf("%s: catalogue still cached", step.name)
+		}
+
+		if titles := catalogueTitles(t); !slices.Equal(titles, step.want) {
+			t.Errorf("%s: catalogue %q, want %q", step.name, titles, step.want)
+		}
+	}
+}
+
+func TestCachedProductsServesFromCache(t *testing.T) {
+	db := testutil.DB(t)
+	testutil.Redis(t)
+
+	if err := db.Create(&models.Product{Title: "Mug", Price: 10}).Error; err != nil {
+		t.Fatal(err)
+	}
+	catalogueTitles(t)
+
+	// Raw SQL skips the hooks, so the cached catalogue is served until it expires
+	if err := db.Exec("UPDATE products SET title = ?", "Cup").Error; err != nil {
+		t.Fatal(err)
+	}
+	if titles := catalogueTitles(t); !slices.Equal(titles, []string{"Mug"}) {
+		t.Errorf("catalogue %q, want the cached [Mug]", titles)
+	}
+}
@@ -1 +1,95 @@
 package database
+
+import (
+	"fmt"
+	"go-ambassador/src/config"
+	"go-ambassador/src/models"
+	"log"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// maxConnectBackoff caps the delay between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// DB is the shared GORM connection used by controllers and commands
+var DB *gorm.DB
+
+// Connect opens the MySQL connection described by cfg and stores it in DB
+// While the database container is still booting, the connection is retried
+// with exponential backoff up to cfg.ConnectAttempts times
+// The last connection error is returned if every attempt fails
+func Connect(cfg config.DatabaseConfig) error {
+	var err error
+	backoff := cfg.ConnectBackoff.Std()
+
+	for attempt := 1; attempt <= cfg.ConnectAttempts; attempt++ {
+		// gorm.Open pings the server, so a nil error means MySQL is reachable
+		// Foreign keys are not enforced on users.role_id since ambassadors have no role
+		// TranslateError turns unique key violations into gorm.ErrDuplicatedKey
+		DB, err = gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{
+			DisableForeignKeyConstraintWhenMigrating: true,
+			TranslateError:                           true,
+		})
+		if err == nil {
+			break
+		}
+
+		if attempt == cfg.ConnectAttempts {
+			return fmt.Errorf("connecting to database after %d attempts: %w", attempt, err)
+		}
+
+		log.Printf("database not ready (attempt %d/%d): %v; retrying in %s", attempt, cfg.ConnectAttempts, err, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxConnectBackoff {
+			backoff = maxConnectBackoff
+		}
+	}
+
+	// Configure the underlying connection pool
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime.Std())
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime.Std())
+
+	return nil
+}
+
+// AutoMigrate creates or updates the tables for all models
+func AutoMigrate() error {
+	return DB.AutoMigrate(
+		models.User{},
+		models.Role{},
+		models.Permission{},
+		models.Product{},
+		models.Link{},
+		models.Order{},
+		models.OrderItem{},
+		models.Session{},
+		models.PasswordToken{},
+		models.ExportJob{},
+	)
+}
+
+// Close releases all pooled connections, it is safe to call if Connect failed
+func Close() error {
+	if DB == nil {
+		return nil
+	}
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
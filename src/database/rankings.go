@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/models"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RankingsKey is the Redis sorted set holding ambassador revenue
+// Members are ambassador user IDs and scores are their revenue from paid orders
+const RankingsKey = "rankings"
+
+// Ranking is a single leaderboard entry
+type Ranking struct {
+	UserId  uint
+	Revenue float64
+}
+
+// IncrementRanking adds amount to the ambassador's score
+// A negative amount removes revenue, e.g. when a paid order is refunded
+func IncrementRanking(ctx context.Context, userId uint, amount float64) error {
+	if err := Cache.ZIncrBy(ctx, RankingsKey, amount, strconv.Itoa(int(userId))).Err(); err != nil {
+		return err
+	}
+
+	return cache.Invalidate(ctx, models.RankingsCacheTag)
+}
+
+// Rankings returns the leaderboard ordered by revenue, highest first
+func Rankings(ctx context.Context) ([]Ranking, error) {
+	members, err := Cache.ZRevRangeWithScores(ctx, RankingsKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	rankings := make([]Ranking, 0, len(members))
+	for _, member := range members {
+		id, _ := strconv.Atoi(member.Member.(string))
+		rankings = append(rankings, Ranking{UserId: uint(id), Revenue: member.Score})
+	}
+
+	return rankings, nil
+}
+
+// RebuildRankings recomputes the leaderboard from MySQL
+// Every ambassador gets a score equal to the ambassador revenue of their paid orders
+// The new set is built under a temporary key and swapped in atomically
+func RebuildRankings(ctx context.Context) error {
+	var ambassadors []models.User
+	if err := DB.Where("is_ambassador = ?", true).Find(&ambassadors).Error; err != nil {
+		return err
+	}
+
+	var revenues []Ranking
+	err := DB.Raw(`
+		SELECT o.user_id, SUM(oi.ambassador_revenue) as revenue
+		FROM orders o
+		JOIN order_items oi on o.id=oi.order_id
+		WHERE o.status = ?
+		GROUP BY o.user_id
+		`, models.OrderStatusPaid).Scan(&revenues).Error
+	if err != nil {
+		return err
+	}
+
+	scores := make(map[uint]float64, len(revenues))
+	for _, revenue := range revenues {
+		scores[revenue.UserId] = revenue.Revenue
+	}
+
+	members := make([]redis.Z, 0, len(ambassadors))
+	for _, ambassador := range ambassadors {
+		members = append(members, redis.Z{
+			Score:  scores[ambassador.Id],
+			Member: strconv.Itoa(int(ambassador.Id)),
+		})
+	}
+
+	if len(members) == 0 {
+		if err := Cache.Del(ctx, RankingsKey).Err(); err != nil {
+			return err
+		}
+		return cache.Invalidate(ctx, models.RankingsCacheTag)
+	}
+
+	tmpKey := RankingsKey + ":rebuild"
+
+	_, err = Cache.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, tmpKey)
+		pipe.ZAdd(ctx, tmpKey, members...)
+		pipe.Rename(ctx, tmpKey, RankingsKey)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return cache.Invalidate(ctx, models.RankingsCacheTag)
+}
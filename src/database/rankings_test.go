@@ -0,0 +1,104 @@
+package database_test
+
+import (
+	"context"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestIncrementRanking(t *testing.T) {
+	testutil.Redis(t)
+	ctx := context.Background()
+
+	increments := []database.Ranking{
+		{UserId: 1, Revenue: 5},
+		{UserId: 2, Revenue: 12.5},
+		{UserId: 1, Revenue: 10},
+		{UserId: 3, Revenue: 1},
+		// A refund takes revenue away again
+		{UserId: 2, Revenue: -7.5},
+	}
+
+	for _, increment := range increments {
+		if err := database.IncrementRanking(ctx, increment.UserId, increment.Revenue); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rankings, err := database.Rankings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []database.Ranking{{UserId: 1, Revenue: 15}, {UserId: 2, Revenue: 5}, {UserId: 3, Revenue: 1}}
+	if !reflect.DeepEqual(rankings, want) {
+		t.Errorf("rankings %v, want %v", rankings, want)
+	}
+}
+
+func TestRebuildRankings(t *testing.T) {
+	db := testutil.DB(t)
+	server := testutil.Redis(t)
+	ctx := context.Background()
+
+	alice := testutil.CreateUser(t, models.User{IsAmbassador: true})
+	bob := testutil.CreateUser(t, models.User{IsAmbassador: true})
+	admin := testutil.CreateUser(t, models.User{RoleId: 1})
+
+	orders := []models.Order{
+		{UserId: alice.Id, Status: models.OrderStatusPaid, OrderItems: []models.OrderItem{{AmbassadorRevenue: 3}, {AmbassadorRevenue: 2}}},
+		{UserId: alice.Id, Status: models.OrderStatusPaid, OrderItems: []models.OrderItem{{AmbassadorRevenue: 4}}},
+		// Only paid orders count
+		{UserId: alice.Id, Status: models.OrderStatusPending, OrderItems: []models.OrderItem{{AmbassadorRevenue: 100}}},
+		{UserId: bob.Id, Status: models.OrderStatusRefunded, OrderItems: []models.OrderItem{{AmbassadorRevenue: 50}}},
+	}
+	if err := db.Create(&orders).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Drift: a score that MySQL does not back and a member that no longer exists
+	server.ZAdd(database.RankingsKey, 999, "1")
+	server.ZAdd(database.RankingsKey, 10, "404")
+
+	if err := database.RebuildRankings(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rankings, err := database.Rankings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []database.Ranking{{UserId: alice.Id, Revenue: 9}, {UserId: bob.Id, Revenue: 0}}
+	if !reflect.DeepEqual(rankings, want) {
+		t.Errorf("rankings %v, want %v", rankings, want)
+	}
+
+	for _, ranking := range rankings {
+		if ranking.UserId == admin.Id {
+			t.Errorf("admin %d is ranked", admin.Id)
+		}
+	}
+
+	if server.Exists(database.RankingsKey + ":rebuild") {
+		t.Error("temporary rebuild key left behind")
+	}
+}
+
+func TestRebuildRankingsWithoutAmbassadors(t *testing.T) {
+	testutil.DB(t)
+	server := testutil.Redis(t)
+
+	server.ZAdd(database.RankingsKey, 10, "1")
+
+	if err := database.RebuildRankings(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.Exists(database.RankingsKey) {
+		t.Error("rankings kept without any ambassador")
+	}
+}
@@ -1 +1,19 @@
 package database
+
+import (
+	"go-ambassador/src/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is the shared Redis client
+var Cache *redis.Client
+
+// SetupRedis creates the Redis client described by cfg and stores it in Cache
+func SetupRedis(cfg config.RedisConfig) {
+	Cache = redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
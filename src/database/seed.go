@@ -0,0 +1,130 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"go-ambassador/src/config"
+	"go-ambassador/src/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// defaultRoles are created on first start, in this order so that their IDs are
+// stable: Admin=1, Editor=2, Viewer=3
+var defaultRoles = []string{"Admin", "Editor", "Viewer"}
+
+// SeedPermissions makes sure the view_ and edit_ permissions exist for every resource
+// and creates the default roles when the roles table is empty
+// It is idempotent and safe to run on every start
+func SeedPermissions() error {
+	var permissions []models.Permission
+
+	for _, resource := range models.PermissionResources {
+		for _, name := range []string{models.ViewPermission(resource), models.EditPermission(resource)} {
+			permission := models.Permission{Name: name}
+			if err := DB.Where(permission).FirstOrCreate(&permission).Error; err != nil {
+				return err
+			}
+			permissions = append(permissions, permission)
+		}
+	}
+
+	// Roles are only seeded once so later edits by admins are kept
+	var count int64
+	if err := DB.Model(&models.Role{}).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	for _, name := range defaultRoles {
+		role := models.Role{
+			Name:        name,
+			Permissions: defaultRolePermissions(name, permissions),
+		}
+
+		if err := DB.Create(&role).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// defaultRolePermissions picks the permissions granted to a default role
+// Admin gets everything, Editor everything except managing users and roles,
+// Viewer only view_ permissions
+func defaultRolePermissions(role string, permissions []models.Permission) []models.Permission {
+	var granted []models.Permission
+
+	for _, permission := range permissions {
+		switch role {
+		case "Admin":
+			granted = append(granted, permission)
+		case "Editor":
+			if permission.Name != models.EditPermission("users") && permission.Name != models.EditPermission("roles") {
+				granted = append(granted, permission)
+			}
+		case "Viewer":
+			if strings.HasPrefix(permission.Name, "view_") {
+				granted = append(granted, permission)
+			}
+		}
+	}
+
+	return granted
+}
+
+// SeedAdmin creates the first admin from cfg when no user holds the Admin role
+// An existing account with the same email is given the Admin role instead; its
+// password is left alone. Nothing happens when cfg.Email is empty
+// It must run after SeedPermissions and is safe to run on every start
+func SeedAdmin(cfg config.AdminConfig) error {
+	if cfg.Email == "" {
+		return nil
+	}
+
+	var role models.Role
+	if err := DB.Where("name = ?", "Admin").First(&role).Error; err != nil {
+		return fmt.Errorf("finding the Admin role: %w", err)
+	}
+
+	var count int64
+	if err := DB.Model(&models.User{}).Where("role_id = ?", role.Id).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	var user models.User
+	err := DB.Where("email = ?", cfg.Email).First(&user).Error
+	if err == nil {
+		if user.IsAmbassador {
+			return fmt.Errorf("ADMIN_EMAIL %s belongs to an ambassador", cfg.Email)
+		}
+
+		return DB.Model(&user).Update("role_id", role.Id).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	// The address comes from the operator, so it does not need verifying
+	now := time.Now()
+	user = models.User{
+		FirstName:       cfg.FirstName,
+		LastName:        cfg.LastName,
+		Email:           cfg.Email,
+		RoleId:          role.Id,
+		EmailVerifiedAt: &now,
+	}
+	user.SetPassword(cfg.Password)
+
+	return DB.Create(&user).Error
+}
@@ -0,0 +1,113 @@
+package database_test
+
+import (
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"strings"
+	"testing"
+)
+
+// firstAdmin is the account described by ADMIN_EMAIL and friends
+var firstAdmin = config.AdminConfig{
+	Email:     "first@example.com",
+	Password:  "first-password",
+	FirstName: "First",
+	LastName:  "Admin",
+}
+
+// admins returns the users holding the Admin role
+func admins(t *testing.T) []models.User {
+	t.Helper()
+
+	var users []models.User
+	err := database.DB.Joins("JOIN roles ON roles.id = users.role_id").Where("roles.name = ?", "Admin").Find(&users).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return users
+}
+
+func TestSeedAdminCreatesFirstAdmin(t *testing.T) {
+	testutil.DB(t)
+
+	// Running it again must not create a second account
+	for i := 0; i < 2; i++ {
+		if err := database.SeedAdmin(firstAdmin); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	users := admins(t)
+	if len(users) != 1 {
+		t.Fatalf("%d admins, want 1", len(users))
+	}
+
+	user := users[0]
+	if user.Email != firstAdmin.Email || user.IsAmbassador || !user.IsVerified() {
+		t.Errorf("admin %+v: want a verified admin for %s", user, firstAdmin.Email)
+	}
+	if err := user.ComparePassword(firstAdmin.Password); err != nil {
+		t.Errorf("password not set: %v", err)
+	}
+}
+
+func TestSeedAdminSkipsWhenAdminExists(t *testing.T) {
+	testutil.DB(t)
+	existing := testutil.CreateUser(t, models.User{RoleId: 1})
+
+	if err := database.SeedAdmin(firstAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	if users := admins(t); len(users) != 1 || users[0].Id != existing.Id {
+		t.Errorf("admins %+v: want only the existing one", users)
+	}
+}
+
+func TestSeedAdminPromotesExistingAccount(t *testing.T) {
+	testutil.DB(t)
+	existing := testutil.CreateUser(t, models.User{Email: firstAdmin.Email})
+
+	if err := database.SeedAdmin(firstAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	users := admins(t)
+	if len(users) != 1 || users[0].Id != existing.Id {
+		t.Fatalf("admins %+v: want the existing account", users)
+	}
+
+	// The password the account already had is kept
+	if err := users[0].ComparePassword(testutil.Password); err != nil {
+		t.Errorf("password changed: %v", err)
+	}
+}
+
+func TestSeedAdminRejectsAmbassadorEmail(t *testing.T) {
+	testutil.DB(t)
+	testutil.CreateUser(t, models.User{Email: firstAdmin.Email, IsAmbassador: true})
+
+	err := database.SeedAdmin(firstAdmin)
+	if err == nil || !strings.Contains(err.Error(), "ambassador") {
+		t.Fatalf("error %v: want the ambassador to be refused", err)
+	}
+
+	if users := admins(t); len(users) != 0 {
+		t.Errorf("admins %+v: want none", users)
+	}
+}
+
+func TestSeedAdminWithoutEmail(t *testing.T) {
+	testutil.DB(t)
+
+	if err := database.SeedAdmin(config.AdminConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if users := admins(t); len(users) != 0 {
+		t.Errorf("admins %+v: want none", users)
+	}
+}
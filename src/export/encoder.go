@@ -0,0 +1,304 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"go-ambassador/src/models"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// orderEncoder writes orders in one format and layout
+type orderEncoder interface {
+	// Order writes the order and its items
+	Order(order *models.Order) error
+	// Flush writes buffered rows to the underlying writer
+	Flush() error
+	// Close finishes the document
+	Close() error
+	// Release frees temporary resources, whether or not Close was called
+	Release()
+}
+
+// groupedColumns is the header of the grouped tabular layout
+var groupedColumns = []interface{}{"ID", "Name", "Email", "Product Title", "Price", "Quantity"}
+
+// flatColumns is the header of the flat tabular layout
+var flatColumns = []interface{}{
+	"Order ID", "Created At", "Status", "Name", "Email", "Code", "Ambassador Email",
+	"Product Title", "Price", "Quantity",
+}
+
+// newOrderEncoder creates the encoder for options.Format and options.Layout
+// and writes the header
+func newOrderEncoder(w io.Writer, options OrderOptions) (orderEncoder, error) {
+	if options.Format == FormatNDJSON {
+		return &ndjsonEncoder{encoder: json.NewEncoder(w), flat: options.Layout == LayoutFlat}, nil
+	}
+
+	var t table
+	if options.Format == FormatXLSX {
+		xlsx, err := newXLSXTable(w)
+		if err != nil {
+			return nil, err
+		}
+		t = xlsx
+	} else {
+		t = &csvTable{writer: csv.NewWriter(w)}
+	}
+
+	encoder := &tableEncoder{table: t, flat: options.Layout == LayoutFlat}
+
+	header := groupedColumns
+	if encoder.flat {
+		header = flatColumns
+	}
+
+	if err := t.WriteRow(header); err != nil {
+		t.Release()
+		return nil, err
+	}
+
+	return encoder, nil
+}
+
+// tableEncoder writes orders as rows of a CSV or XLSX table
+type tableEncoder struct {
+	table table
+	flat  bool
+}
+
+// Order writes the order in the grouped or flat layout
+func (e *tableEncoder) Order(order *models.Order) error {
+	if e.flat {
+		for _, item := range order.OrderItems {
+			row := []interface{}{
+				order.Id,
+				order.CreateAt.UTC().Format(time.RFC3339),
+				order.Status,
+				order.FirstName + " " + order.LastName,
+				order.Email,
+				order.Code,
+				order.AmbassadorEmail,
+				item.ProductTitle,
+				item.Price,
+				item.Quantity,
+			}
+			if err := e.table.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// The order row leaves the product columns empty and the item rows leave
+	// the order columns empty, grouping the items visually under their order
+	row := []interface{}{order.Id, order.FirstName + " " + order.LastName, order.Email, "", "", ""}
+	if err := e.table.WriteRow(row); err != nil {
+		return err
+	}
+
+	for _, item := range order.OrderItems {
+		row := []interface{}{"", "", "", item.ProductTitle, item.Price, item.Quantity}
+		if err := e.table.WriteRow(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush writes buffered rows to the underlying writer
+func (e *tableEncoder) Flush() error {
+	return e.table.Flush()
+}
+
+// Close finishes the table
+func (e *tableEncoder) Close() error {
+	return e.table.Close()
+}
+
+// Release frees the table's temporary resources
+func (e *tableEncoder) Release() {
+	e.table.Release()
+}
+
+// flatItem is a single NDJSON line of the flat layout
+type flatItem struct {
+	OrderId         uint      `json:"order_id"`
+	CreateAt        time.Time `json:"create_at"`
+	Status          string    `json:"status"`
+	FirstName       string    `json:"first_name"`
+	LastName        string    `json:"last_name"`
+	Email           string    `json:"email"`
+	Code            string    `json:"code"`
+	AmbassadorEmail string    `json:"ambassador_email"`
+	ProductTitle    string    `json:"product_title"`
+	Price           float64   `json:"price"`
+	Quantity        uint      `json:"quantity"`
+}
+
+// ndjsonEncoder writes one JSON document per line: an order with its items in
+// the grouped layout or a flatItem in the flat layout
+type ndjsonEncoder struct {
+	encoder *json.Encoder
+	flat    bool
+}
+
+// Order writes the order as one line, or one line per item when flat
+func (e *ndjsonEncoder) Order(order *models.Order) error {
+	if !e.flat {
+		return e.encoder.Encode(order)
+	}
+
+	for _, item := range order.OrderItems {
+		line := flatItem{
+			OrderId:         order.Id,
+			CreateAt:        order.CreateAt,
+			Status:          order.Status,
+			FirstName:       order.FirstName,
+			LastName:        order.LastName,
+			Email:           order.Email,
+			Code:            order.Code,
+			AmbassadorEmail: order.AmbassadorEmail,
+			ProductTitle:    item.ProductTitle,
+			Price:           item.Price,
+			Quantity:        item.Quantity,
+		}
+		if err := e.encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush is a no-op; the JSON encoder writes every line directly
+func (e *ndjsonEncoder) Flush() error {
+	return nil
+}
+
+// Close is a no-op; NDJSON has no trailer
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// Release is a no-op; nothing is buffered
+func (e *ndjsonEncoder) Release() {}
+
+// table is a spreadsheet-like sink of rows
+type table interface {
+	WriteRow(cells []interface{}) error
+	Flush() error
+	Close() error
+	Release()
+}
+
+// csvTable writes rows as CSV
+type csvTable struct {
+	writer *csv.Writer
+}
+
+// WriteRow formats the cells and writes them as one CSV record
+func (t *csvTable) WriteRow(cells []interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		record[i] = formatCell(cell)
+	}
+
+	return t.writer.Write(record)
+}
+
+// Flush writes buffered records to the underlying writer
+func (t *csvTable) Flush() error {
+	t.writer.Flush()
+	return t.writer.Error()
+}
+
+// Close flushes the remaining records
+func (t *csvTable) Close() error {
+	return t.Flush()
+}
+
+// Release is a no-op; the CSV writer holds no temporary resources
+func (t *csvTable) Release() {}
+
+// formatCell renders a cell for CSV, keeping the full precision of prices
+func formatCell(cell interface{}) string {
+	switch value := cell.(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case uint:
+		return strconv.FormatUint(uint64(value), 10)
+	default:
+		return ""
+	}
+}
+
+// xlsxSheet is the name of the single worksheet of an XLSX export
+const xlsxSheet = "Orders"
+
+// xlsxTable writes rows to an XLSX workbook
+// An XLSX file is a zip archive that can only be written once complete, so the
+// excelize stream writer spools rows to a temporary file and Close sends the
+// workbook to the underlying writer
+type xlsxTable struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// newXLSXTable creates a workbook with a single Orders sheet
+func newXLSXTable(w io.Writer) (*xlsxTable, error) {
+	file := excelize.NewFile()
+
+	if err := file.SetSheetName(file.GetSheetName(0), xlsxSheet); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxTable{w: w, file: file, stream: stream}, nil
+}
+
+// WriteRow appends the cells as the next row
+func (t *xlsxTable) WriteRow(cells []interface{}) error {
+	t.row++
+
+	cell, err := excelize.CoordinatesToCellName(1, t.row)
+	if err != nil {
+		return err
+	}
+
+	return t.stream.SetRow(cell, cells)
+}
+
+// Flush is a no-op; rows stay in the workbook until Close
+func (t *xlsxTable) Flush() error {
+	return nil
+}
+
+// Close finishes the sheet and writes the workbook
+func (t *xlsxTable) Close() error {
+	if err := t.stream.Flush(); err != nil {
+		return err
+	}
+
+	return t.file.Write(t.w)
+}
+
+// Release removes the rows spooled to a temporary file
+func (t *xlsxTable) Release() {
+	t.file.Close()
+}
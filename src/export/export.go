@@ -0,0 +1,220 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/models"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	FormatCSV    = "csv"
+	FormatXLSX   = "xlsx"
+	FormatNDJSON = "ndjson"
+)
+
+// Export layouts
+// Grouped writes one row per order followed by one row per item; flat repeats
+// the order columns on every item row
+const (
+	LayoutGrouped = "grouped"
+	LayoutFlat    = "flat"
+)
+
+// BatchSize is the number of orders loaded per query
+const BatchSize = 500
+
+// Formats lists the supported formats
+var Formats = []string{FormatCSV, FormatXLSX, FormatNDJSON}
+
+// Layouts lists the supported layouts
+var Layouts = []string{LayoutGrouped, LayoutFlat}
+
+// contentTypes maps each format to its MIME type
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// ContentType returns the MIME type of format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Filename returns the download name of an export created at t
+func Filename(format string, t time.Time) string {
+	return fmt.Sprintf("orders-%s.%s", t.Format("20060102-150405"), format)
+}
+
+// OrderOptions selects the orders to export and how to write them
+// It is plain data so it can be stored with a queued export
+type OrderOptions struct {
+	Format   string   `json:"format"`
+	Layout   string   `json:"layout"`
+	Statuses []string `json:"statuses"`
+	// From is the inclusive lower bound on create_at
+	From *time.Time `json:"from,omitempty"`
+	// To is the upper bound on create_at, exclusive when ToExclusive is set
+	To          *time.Time `json:"to,omitempty"`
+	ToExclusive bool       `json:"to_exclusive,omitempty"`
+}
+
+// Validate checks the format, layout and statuses
+// Returns a 400 error describing the first invalid option
+func (options OrderOptions) Validate() error {
+	if !slices.Contains(Formats, options.Format) {
+		return apperrors.BadRequest("format must be one of " + strings.Join(Formats, ", "))
+	}
+
+	if !slices.Contains(Layouts, options.Layout) {
+		return apperrors.BadRequest("layout must be one of " + strings.Join(Layouts, ", "))
+	}
+
+	for _, status := range options.Statuses {
+		if !slices.Contains(models.OrderStatuses, status) {
+			return apperrors.BadRequest("unknown order status " + status)
+		}
+	}
+
+	return nil
+}
+
+// flusher is implemented by buffered writers such as *bufio.Writer
+type flusher interface {
+	Flush() error
+}
+
+// CancelWriter calls cancel as soon as a Write or Flush to the wrapped writer
+// fails, such as when the client of a streamed response has gone away
+// Fiber never cancels the request context, so a failed write is the only sign
+// of a disconnect and cancelling stops the export at its next batch
+type CancelWriter struct {
+	w      io.Writer
+	cancel context.CancelFunc
+}
+
+// NewCancelWriter wraps w so that a failed write calls cancel
+func NewCancelWriter(w io.Writer, cancel context.CancelFunc) *CancelWriter {
+	return &CancelWriter{w: w, cancel: cancel}
+}
+
+// Write writes p to the wrapped writer and cancels on failure
+func (cw *CancelWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if err != nil {
+		cw.cancel()
+	}
+
+	return n, err
+}
+
+// Flush flushes the wrapped writer, when it buffers, and cancels on failure
+func (cw *CancelWriter) Flush() error {
+	f, ok := cw.w.(flusher)
+	if !ok {
+		return nil
+	}
+
+	if err := f.Flush(); err != nil {
+		cw.cancel()
+		return err
+	}
+
+	return nil
+}
+
+// Orders writes the orders selected by options to w
+// Orders are loaded BatchSize at a time with their items, and each batch is
+// flushed to w before the next one is loaded, so memory use does not grow
+// with the number of orders
+func Orders(ctx context.Context, db *gorm.DB, w io.Writer, options OrderOptions) error {
+	return writeOrders(ctx, db, w, options, nil)
+}
+
+// CountOrders returns the number of orders selected by options
+func CountOrders(ctx context.Context, db *gorm.DB, options OrderOptions) (int64, error) {
+	var total int64
+	err := options.query(db.WithContext(ctx).Model(&models.Order{})).Count(&total).Error
+
+	return total, err
+}
+
+// writeOrders implements Orders; progress, when set, is called after every
+// batch with the number of orders written so far
+func writeOrders(ctx context.Context, db *gorm.DB, w io.Writer, options OrderOptions, progress func(written int64) error) error {
+	encoder, err := newOrderEncoder(w, options)
+	if err != nil {
+		return err
+	}
+	defer encoder.Release()
+
+	query := options.query(db.WithContext(ctx)).Preload("OrderItems")
+
+	var orders []models.Order
+	var written int64
+
+	result := query.FindInBatches(&orders, BatchSize, func(tx *gorm.DB, batch int) error {
+		// Stop between batches once the export has been cancelled; not every
+		// driver aborts a running query when its context is done
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for i := range orders {
+			if err := encoder.Order(&orders[i]); err != nil {
+				return err
+			}
+		}
+
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+
+		// Push the batch to the client before loading the next one
+		if f, ok := w.(flusher); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+
+		written += int64(len(orders))
+		if progress != nil {
+			return progress(written)
+		}
+		return nil
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	return encoder.Close()
+}
+
+// query narrows db to the orders selected by the status and date filters
+func (options OrderOptions) query(db *gorm.DB) *gorm.DB {
+	if len(options.Statuses) > 0 {
+		db = db.Where("status IN ?", options.Statuses)
+	}
+
+	if options.From != nil {
+		db = db.Where("create_at >= ?", *options.From)
+	}
+
+	if options.To != nil {
+		operator := "<="
+		if options.ToExclusive {
+			operator = "<"
+		}
+		db = db.Where("create_at "+operator+" ?", *options.To)
+	}
+
+	return db
+}
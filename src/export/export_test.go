@@ -0,0 +1,328 @@
+package export_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"go-ambassador/src/export"
+	"go-ambassador/src/models"
+	"go-ambassador/src/testutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// day is the creation date of the orders in these tests
+var day = time.Date(2026, 3, 14, 0, 0, 0, 0, time.UTC)
+
+// createOrder stores a buyer's order created hours after day with the items
+func createOrder(t *testing.T, db *gorm.DB, hours int, status string, items ...models.OrderItem) models.Order {
+	t.Helper()
+
+	order := models.Order{
+		Code:            "code1",
+		AmbassadorEmail: "amb@example.com",
+		FirstName:       "Buyer",
+		LastName:        "One",
+		Email:           "buyer@example.com",
+		Status:          status,
+		CreateAt:        day.Add(time.Duration(hours) * time.Hour),
+		OrderItems:      items,
+	}
+
+	if err := db.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return order
+}
+
+// item builds an order item
+func item(title string, price float64, quantity uint) models.OrderItem {
+	return models.OrderItem{ProductTitle: title, Price: price, Quantity: quantity}
+}
+
+// exportOrders runs the export into a buffer
+func exportOrders(t *testing.T, db *gorm.DB, options export.OrderOptions) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := export.Orders(context.Background(), db, &buf, options); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// readCSV parses the exported CSV
+func readCSV(t *testing.T, data []byte) [][]string {
+	t.Helper()
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+// paidOrders stores two paid orders and a pending one, which the default
+// status filter leaves out
+func paidOrders(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	createOrder(t, db, 1, models.OrderStatusPaid, item("Mug", 10.5, 2), item("Pen", 1.25, 1))
+	createOrder(t, db, 2, models.OrderStatusPending, item("Hat", 20, 1))
+	createOrder(t, db, 3, models.OrderStatusPaid, item("Cap", 7, 3))
+}
+
+// paidStatuses is the status filter the export controller applies by default
+var paidStatuses = []string{models.OrderStatusPaid}
+
+func TestOrdersCSVGrouped(t *testing.T) {
+	db := testutil.DB(t)
+	paidOrders(t, db)
+
+	got := readCSV(t, exportOrders(t, db, export.OrderOptions{
+		Format:   export.FormatCSV,
+		Layout:   export.LayoutGrouped,
+		Statuses: paidStatuses,
+	}))
+
+	want := [][]string{
+		{"ID", "Name", "Email", "Product Title", "Price", "Quantity"},
+		{"1", "Buyer One", "buyer@example.com", "", "", ""},
+		{"", "", "", "Mug", "10.5", "2"},
+		{"", "", "", "Pen", "1.25", "1"},
+		{"3", "Buyer One", "buyer@example.com", "", "", ""},
+		{"", "", "", "Cap", "7", "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOrdersCSVFlat(t *testing.T) {
+	db := testutil.DB(t)
+	paidOrders(t, db)
+
+	got := readCSV(t, exportOrders(t, db, export.OrderOptions{
+		Format:   export.FormatCSV,
+		Layout:   export.LayoutFlat,
+		Statuses: paidStatuses,
+	}))
+
+	want := [][]string{
+		{"Order ID", "Created At", "Status", "Name", "Email", "Code", "Ambassador Email", "Product Title", "Price", "Quantity"},
+		{"1", "2026-03-14T01:00:00Z", "paid", "Buyer One", "buyer@example.com", "code1", "amb@example.com", "Mug", "10.5", "2"},
+		{"1", "2026-03-14T01:00:00Z", "paid", "Buyer One", "buyer@example.com", "code1", "amb@example.com", "Pen", "1.25", "1"},
+		{"3", "2026-03-14T03:00:00Z", "paid", "Buyer One", "buyer@example.com", "code1", "amb@example.com", "Cap", "7", "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOrdersXLSX(t *testing.T) {
+	db := testutil.DB(t)
+	paidOrders(t, db)
+
+	data := exportOrders(t, db, export.OrderOptions{
+		Format:   export.FormatXLSX,
+		Layout:   export.LayoutGrouped,
+		Statuses: paidStatuses,
+	})
+
+	file, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	got, err := file.GetRows("Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// GetRows drops trailing empty cells
+	want := [][]string{
+		{"ID", "Name", "Email", "Product Title", "Price", "Quantity"},
+		{"1", "Buyer One", "buyer@example.com"},
+		{"", "", "", "Mug", "10.5", "2"},
+		{"", "", "", "Pen", "1.25", "1"},
+		{"3", "Buyer One", "buyer@example.com"},
+		{"", "", "", "Cap", "7", "3"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOrdersFilters(t *testing.T) {
+	db := testutil.DB(t)
+	createOrder(t, db, 0, models.OrderStatusPaid, item("Mug", 1, 1))
+	createOrder(t, db, 12, models.OrderStatusRefunded, item("Mug", 1, 1))
+	createOrder(t, db, 24, models.OrderStatusPaid, item("Mug", 1, 1))
+	createOrder(t, db, 36, models.OrderStatusPending, item("Mug", 1, 1))
+
+	next := day.Add(24 * time.Hour)
+
+	tests := []struct {
+		name    string
+		options export.OrderOptions
+		want    []uint
+	}{
+		{"all statuses", export.OrderOptions{}, []uint{1, 2, 3, 4}},
+		{"paid", export.OrderOptions{Statuses: paidStatuses}, []uint{1, 3}},
+		{"paid or refunded", export.OrderOptions{Statuses: []string{models.OrderStatusPaid, models.OrderStatusRefunded}}, []uint{1, 2, 3}},
+		{"from is inclusive", export.OrderOptions{From: &next}, []uint{3, 4}},
+		{"to is inclusive", export.OrderOptions{To: &next}, []uint{1, 2, 3}},
+		{"exclusive to", export.OrderOptions{To: &next, ToExclusive: true}, []uint{1, 2}},
+		{"status and dates", export.OrderOptions{Statuses: paidStatuses, From: &day, To: &next, ToExclusive: true}, []uint{1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Format = export.FormatNDJSON
+			tt.options.Layout = export.LayoutGrouped
+
+			var got []uint
+			scanner := bufio.NewScanner(bytes.NewReader(exportOrders(t, db, tt.options)))
+			for scanner.Scan() {
+				var order models.Order
+				if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, order.Id)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("orders %v, want %v", got, tt.want)
+			}
+
+			total, err := export.CountOrders(context.Background(), db, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != int64(len(tt.want)) {
+				t.Errorf("count %d, want %d", total, len(tt.want))
+			}
+		})
+	}
+}
+
+// manyOrders stores one order more than fits in a batch, so an export that
+// kept going after its first batch would write again
+func manyOrders(t *testing.T, db *gorm.DB) {
+	t.Helper()
+
+	orders := make([]models.Order, export.BatchSize+1)
+	for i := range orders {
+		orders[i] = models.Order{FirstName: "Buyer", Status: models.OrderStatusPaid}
+	}
+
+	if err := db.CreateInBatches(&orders, 100).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// brokenWriter is a client that has gone away: every write fails or, when
+// failFlush is set, writes are buffered and the flush fails
+type brokenWriter struct {
+	writes    int
+	failFlush bool
+}
+
+func (w *brokenWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.failFlush {
+		return len(p), nil
+	}
+	return 0, errors.New("connection reset by peer")
+}
+
+func (w *brokenWriter) Flush() error {
+	if w.failFlush {
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func TestOrdersStopsWhenTheClientGoesAway(t *testing.T) {
+	db := testutil.DB(t)
+	manyOrders(t, db)
+
+	for _, format := range []string{export.FormatCSV, export.FormatNDJSON} {
+		for _, failFlush := range []bool{false, true} {
+			w := &brokenWriter{failFlush: failFlush}
+			ctx, cancel := context.WithCancel(context.Background())
+
+			err := export.Orders(ctx, db, export.NewCancelWriter(w, cancel), export.OrderOptions{Format: format, Layout: export.LayoutGrouped})
+			if err == nil {
+				t.Errorf("%s, failing flush %v: export succeeded", format, failFlush)
+			}
+			if ctx.Err() == nil {
+				t.Errorf("%s, failing flush %v: context not cancelled", format, failFlush)
+			}
+
+			// A failed write ends the export at once; a failed flush at the end
+			// of the first batch
+			maxWrites := 1
+			if failFlush {
+				maxWrites = export.BatchSize
+			}
+			if w.writes > maxWrites {
+				t.Errorf("%s, failing flush %v: %d writes after the failure", format, failFlush, w.writes)
+			}
+
+			cancel()
+		}
+	}
+}
+
+func TestOrdersStopsWhenCancelledBetweenBatches(t *testing.T) {
+	db := testutil.DB(t)
+	manyOrders(t, db)
+
+	// Cancel as soon as the first batch has been flushed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lines int
+	w := writerFunc(func(p []byte) (int, error) {
+		lines += bytes.Count(p, []byte("\n"))
+		return len(p), nil
+	})
+
+	err := export.Orders(ctx, db, flushHook{w, cancel}, export.OrderOptions{Format: export.FormatNDJSON, Layout: export.LayoutGrouped})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error %v, want context.Canceled", err)
+	}
+	if lines != export.BatchSize {
+		t.Errorf("%d orders written, want only the first batch of %d", lines, export.BatchSize)
+	}
+}
+
+// writerFunc adapts a function to io.Writer
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
+
+// flushHook calls cancel when the export flushes a batch
+type flushHook struct {
+	writerFunc
+	cancel context.CancelFunc
+}
+
+func (h flushHook) Flush() error {
+	h.cancel()
+	return nil
+}
@@ -0,0 +1,28 @@
+package export
+
+import "time"
+
+// The worker internals used by jobs_test.go, which lives in export_test
+// because testutil imports this package
+
+var (
+	Claim        = claim
+	Run          = run
+	Heartbeat    = heartbeat
+	Progress     = progress
+	FileKey      = fileKey
+	ErrLeaseLost = errLeaseLost
+)
+
+// SetLeaseDuration shortens the lease for a test, returning a function that restores it
+func SetLeaseDuration(d time.Duration) func() {
+	previous := leaseDuration
+	leaseDuration = d
+
+	return func() { leaseDuration = previous }
+}
+
+// LeaseDuration returns the current lease duration
+func LeaseDuration() time.Duration {
+	return leaseDuration
+}
@@ -0,0 +1,372 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/storage"
+	"go-ambassador/src/util"
+	"log"
+	"maps"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// pollInterval is how often idle workers look for jobs queued by other
+// instances or left behind by a crashed one
+const pollInterval = 5 * time.Second
+
+// leaseDuration is how long a claimed job stays with its worker without a
+// heartbeat; the worker renews the lease every third of it, so a job whose
+// lease ran out belongs to a worker that died or stalled and is queued again
+var leaseDuration = time.Minute
+
+// leaseTokenLength is the number of random characters in a lease token
+const leaseTokenLength = 32
+
+// errLeaseLost cancels a job whose lease was taken over by another worker
+var errLeaseLost = errors.New("export: lease lost")
+
+// wake signals idle workers that a job was queued by this instance
+var wake = make(chan struct{}, 1)
+
+// downloadTTL is how long a signed download link stays valid
+var downloadTTL = time.Hour
+
+// Setup applies the export job settings from cfg
+func Setup(cfg config.ExportsConfig) {
+	downloadTTL = cfg.DownloadTTL.Std()
+}
+
+// DownloadTTL returns how long a signed download link stays valid
+func DownloadTTL() time.Duration {
+	return downloadTTL
+}
+
+// Enqueue stores a queued export job for options and wakes a worker
+func Enqueue(ctx context.Context, userId uint, options OrderOptions) (*models.ExportJob, error) {
+	encoded, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+
+	job := models.ExportJob{
+		UserId:  userId,
+		Status:  models.ExportJobQueued,
+		Options: encoded,
+	}
+
+	if err := database.DB.WithContext(ctx).Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return &job, nil
+}
+
+// fileKey is the storage key of the file generated by job
+func fileKey(job *models.ExportJob, format string) string {
+	return fmt.Sprintf("exports/%d.%s", job.Id, format)
+}
+
+// StartWorkers starts count workers that generate queued jobs until ctx is done
+// Jobs are persisted in MySQL, so jobs queued or interrupted before a restart
+// are picked up again; wait on the returned group before closing the database
+func StartWorkers(ctx context.Context, count int) *sync.WaitGroup {
+	var wg sync.WaitGroup
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+
+	return &wg
+}
+
+// work runs queued jobs one at a time and sleeps while there are none
+func work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("export: claiming job: %v", err)
+		}
+
+		if job != nil {
+			run(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim marks the oldest queued job as running, leased to this worker, and
+// returns it
+// The status check in the UPDATE makes sure only one worker, across every
+// instance, wins each job; nil is returned when nothing is queued
+func claim(ctx context.Context) (*models.ExportJob, error) {
+	db := database.DB.WithContext(ctx)
+
+	if err := requeueExpired(db); err != nil {
+		return nil, err
+	}
+
+	for {
+		// Find instead of First, an empty queue is the normal case and not an error
+		var jobs []models.ExportJob
+		err := db.Where("status = ?", models.ExportJobQueued).Order("id").Limit(1).Find(&jobs).Error
+		if err != nil {
+			return nil, err
+		}
+		if len(jobs) == 0 {
+			return nil, nil
+		}
+		job := jobs[0]
+
+		token, err := util.RandomCode(leaseTokenLength)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(leaseDuration)
+		result := db.Model(&models.ExportJob{}).
+			Where("id = ? AND status = ?", job.Id, models.ExportJobQueued).
+			Updates(map[string]interface{}{
+				"status":           models.ExportJobRunning,
+				"started_at":       now,
+				"lease_token":      token,
+				"lease_expires_at": expiresAt,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+
+		// Another worker claimed it first; try the next one
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		job.Status = models.ExportJobRunning
+		job.StartedAt = &now
+		job.LeaseToken = token
+		job.LeaseExpiresAt = &expiresAt
+
+		return &job, nil
+	}
+}
+
+// requeueExpired puts running jobs whose lease ran out back in the queue
+// Jobs claimed before leases existed have none and are requeued once they have
+// not been updated for a whole lease
+func requeueExpired(db *gorm.DB) error {
+	now := time.Now()
+
+	return db.Model(&models.ExportJob{}).
+		Where("status = ?", models.ExportJobRunning).
+		Where("lease_expires_at < ? OR (lease_expires_at IS NULL AND updated_at < ?)", now, now.Add(-leaseDuration)).
+		Updates(map[string]interface{}{
+			"status":           models.ExportJobQueued,
+			"processed":        0,
+			"lease_token":      "",
+			"lease_expires_at": nil,
+		}).Error
+}
+
+// leased narrows the update of job to the lease this worker holds, so a worker
+// that lost its lease cannot overwrite the job
+func leased(ctx context.Context, job *models.ExportJob) *gorm.DB {
+	return database.DB.WithContext(ctx).Model(&models.ExportJob{}).
+		Where("id = ? AND lease_token = ?", job.Id, job.LeaseToken)
+}
+
+// heartbeat renews the lease of job until ctx is done
+// When the lease was lost, because the job was requeued while this worker
+// stalled, cancel is called with errLeaseLost to stop generating it
+func heartbeat(ctx context.Context, job *models.ExportJob, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(leaseDuration / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result := leased(ctx, job).Update("lease_expires_at", time.Now().Add(leaseDuration))
+		if result.Error != nil {
+			// A failed renewal is retried on the next tick, the lease is still
+			// valid for two more
+			if ctx.Err() == nil {
+				log.Printf("export: renewing lease of job %d: %v", job.Id, result.Error)
+			}
+			continue
+		}
+
+		if result.RowsAffected == 0 && !holdsLease(ctx, job) {
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+// holdsLease reports whether the lease of job still belongs to this worker
+// MySQL counts only changed rows as affected, so an update that matched but
+// wrote the same values is told apart from a lost lease with this check
+// Errors count as holding the lease; the next update tries again
+func holdsLease(ctx context.Context, job *models.ExportJob) bool {
+	var count int64
+	if err := leased(ctx, job).Count(&count).Error; err != nil {
+		return true
+	}
+
+	return count > 0
+}
+
+// run generates the job's file and records the outcome
+// The lease is renewed while the file is generated; a job interrupted by
+// shutdown is queued again instead of failing, and a job whose lease was lost
+// is left to the worker that took it over
+func run(ctx context.Context, job *models.ExportJob) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		heartbeat(jobCtx, job, cancel)
+	}()
+
+	var options OrderOptions
+	err := json.Unmarshal(job.Options, &options)
+	if err == nil {
+		err = options.Validate()
+	}
+
+	key := fileKey(job, options.Format)
+	if err == nil {
+		err = generate(jobCtx, job, options, key)
+	}
+
+	// Stop renewing before the outcome releases the lease
+	lost := errors.Is(context.Cause(jobCtx), errLeaseLost) || errors.Is(err, errLeaseLost)
+	cancel(nil)
+	wg.Wait()
+
+	if lost {
+		log.Printf("export: job %d was taken over by another worker", job.Id)
+		return
+	}
+
+	// The job context may be cancelled, the outcome must still be recorded
+	db := leased(context.WithoutCancel(ctx), job)
+	now := time.Now()
+	released := map[string]interface{}{"lease_token": "", "lease_expires_at": nil}
+
+	switch {
+	case err == nil:
+		err = db.Updates(merge(released, map[string]interface{}{
+			"status":      models.ExportJobCompleted,
+			"path":        key,
+			"finished_at": now,
+		})).Error
+	case ctx.Err() != nil:
+		err = db.Updates(merge(released, map[string]interface{}{"status": models.ExportJobQueued, "processed": 0})).Error
+	default:
+		log.Printf("export: job %d failed: %v", job.Id, err)
+
+		if removeErr := storage.Default.Delete(context.WithoutCancel(ctx), key); removeErr != nil {
+			log.Printf("export: removing file of job %d: %v", job.Id, removeErr)
+		}
+
+		err = db.Updates(merge(released, map[string]interface{}{
+			"status":      models.ExportJobFailed,
+			"error":       "export failed",
+			"finished_at": now,
+		})).Error
+	}
+
+	if err != nil {
+		log.Printf("export: updating job %d: %v", job.Id, err)
+	}
+}
+
+// merge returns the union of two sets of column updates
+func merge(a map[string]interface{}, b map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(a)+len(b))
+	maps.Copy(merged, a)
+	maps.Copy(merged, b)
+
+	return merged
+}
+
+// generate writes the export file of job to storage under key, recording the
+// progress after every batch
+func generate(ctx context.Context, job *models.ExportJob, options OrderOptions, key string) error {
+	total, err := CountOrders(ctx, database.DB, options)
+	if err != nil {
+		return err
+	}
+
+	if err := progress(ctx, job, map[string]interface{}{"total": total, "processed": 0}); err != nil {
+		return err
+	}
+
+	file, err := storage.Default.Create(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(file)
+
+	err = writeOrders(ctx, database.DB, w, options, func(written int64) error {
+		return progress(ctx, job, map[string]interface{}{"processed": written})
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// progress records the counts of job, failing with errLeaseLost when another
+// worker has taken the job over
+func progress(ctx context.Context, job *models.ExportJob, counts map[string]interface{}) error {
+	result := leased(ctx, job).Updates(counts)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 && !holdsLease(ctx, job) {
+		return errLeaseLost
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package export_test
+
+import (
+	"context"
+	"encoding/csv"
+	"go-ambassador/src/database"
+	"go-ambassador/src/export"
+	"go-ambassador/src/models"
+	"go-ambassador/src/storage"
+	"go-ambassador/src/testutil"
+	"io"
+	"testing"
+	"time"
+)
+
+// enqueue queues a job with options, failing the test on error
+func enqueue(t *testing.T, options export.OrderOptions) *models.ExportJob {
+	t.Helper()
+
+	job, err := export.Enqueue(context.Background(), 1, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return job
+}
+
+// mustClaim claims the next job and fails the test when there is none
+func mustClaim(t *testing.T) *models.ExportJob {
+	t.Helper()
+
+	job, err := export.Claim(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		t.Fatal("no job claimed")
+	}
+
+	return job
+}
+
+// reload reads job back from the database
+func reload(t *testing.T, job *models.ExportJob) models.ExportJob {
+	t.Helper()
+
+	var reloaded models.ExportJob
+	if err := database.DB.First(&reloaded, job.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return reloaded
+}
+
+// csvOptions exports every order as grouped CSV
+var csvOptions = export.OrderOptions{Format: export.FormatCSV, Layout: export.LayoutGrouped}
+
+func TestClaimTakesEachJobOnce(t *testing.T) {
+	testutil.DB(t)
+	first := enqueue(t, csvOptions)
+	second := enqueue(t, csvOptions)
+
+	for _, want := range []*models.ExportJob{first, second} {
+		job := mustClaim(t)
+		if job.Id != want.Id {
+			t.Fatalf("claimed job %d, want %d", job.Id, want.Id)
+		}
+
+		stored := reload(t, job)
+		if stored.Status != models.ExportJobRunning || stored.StartedAt == nil {
+			t.Errorf("job %d: status %s, started %v", job.Id, stored.Status, stored.StartedAt)
+		}
+		if stored.LeaseToken == "" || stored.LeaseToken != job.LeaseToken || stored.LeaseExpiresAt == nil || !stored.LeaseExpiresAt.After(time.Now()) {
+			t.Errorf("job %d: lease %q until %v", job.Id, stored.LeaseToken, stored.LeaseExpiresAt)
+		}
+	}
+
+	// Both jobs hold a valid lease, so there is nothing left to claim
+	if job, err := export.Claim(context.Background()); err != nil || job != nil {
+		t.Errorf("claimed %+v, %v: want nothing", job, err)
+	}
+}
+
+func TestClaimRequeuesOnlyExpiredLeases(t *testing.T) {
+	testutil.DB(t)
+	enqueue(t, csvOptions)
+	enqueue(t, csvOptions)
+
+	stalled := mustClaim(t)
+	busy := mustClaim(t)
+
+	// A long running job keeps its lease however old it is
+	hourAgo := time.Now().Add(-time.Hour)
+	if err := database.DB.Model(&models.ExportJob{}).Where("id = ?", busy.Id).Update("started_at", hourAgo).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.DB.Model(&models.ExportJob{}).Where("id = ?", stalled.Id).Updates(map[string]interface{}{"lease_expires_at": time.Now().Add(-time.Second), "processed": 7}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	job := mustClaim(t)
+	if job.Id != stalled.Id {
+		t.Fatalf("claimed job %d, want the stalled job %d", job.Id, stalled.Id)
+	}
+	if job.LeaseToken == stalled.LeaseToken {
+		t.Error("requeued job kept the old lease token")
+	}
+	if stored := reload(t, job); stored.Processed != 0 {
+		t.Errorf("requeued job kept processed %d", stored.Processed)
+	}
+
+	if job, err := export.Claim(context.Background()); err != nil || job != nil {
+		t.Errorf("claimed %+v, %v: want the busy job left alone", job, err)
+	}
+
+	// A job left running before leases existed is requeued once it stops changing
+	legacy := enqueue(t, csvOptions)
+	if err := database.DB.Exec("UPDATE export_jobs SET status = ?, updated_at = ? WHERE id = ?", models.ExportJobRunning, hourAgo, legacy.Id).Error; err != nil {
+		t.Fatal(err)
+	}
+	if job := mustClaim(t); job.Id != legacy.Id {
+		t.Errorf("claimed job %d, want the legacy job %d", job.Id, legacy.Id)
+	}
+
+	// The worker that stalled can no longer record progress
+	if err := export.Progress(context.Background(), stalled, map[string]interface{}{"processed": 1}); err != export.ErrLeaseLost {
+		t.Errorf("progress of the stalled worker: %v, want ErrLeaseLost", err)
+	}
+}
+
+func TestHeartbeatRenewsLease(t *testing.T) {
+	testutil.DB(t)
+
+	t.Cleanup(export.SetLeaseDuration(150 * time.Millisecond))
+
+	enqueue(t, csvOptions)
+	job := mustClaim(t)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		export.Heartbeat(ctx, job, cancel)
+	}()
+
+	// Well past the first lease, the job is still leased
+	time.Sleep(3 * export.LeaseDuration())
+	if stored := reload(t, job); !stored.LeaseExpiresAt.After(time.Now()) {
+		t.Fatalf("lease expired at %v", stored.LeaseExpiresAt)
+	}
+	if other, err := export.Claim(context.Background()); err != nil || other != nil {
+		t.Fatalf("claimed %+v, %v: want the leased job left alone", other, err)
+	}
+
+	// Once another worker has taken the job over the heartbeat cancels the run
+	if err := database.DB.Model(&models.ExportJob{}).Where("id = ?", job.Id).Update("lease_token", "other").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("heartbeat kept running after the lease was lost")
+	}
+
+	if cause := context.Cause(ctx); cause != export.ErrLeaseLost {
+		t.Errorf("cause %v, want ErrLeaseLost", cause)
+	}
+}
+
+func TestRunCompletesJob(t *testing.T) {
+	env := testutil.Setup(t)
+	order := models.Order{FirstName: "Buyer", LastName: "One", Email: "buyer@example.com", Status: models.OrderStatusPaid,
+		OrderItems: []models.OrderItem{{ProductTitle: "Mug", Price: 10, Quantity: 2}}}
+	if err := env.DB.Create(&order).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	enqueue(t, export.OrderOptions{Format: export.FormatCSV, Layout: export.LayoutGrouped, Statuses: []string{models.OrderStatusPaid}})
+	job := mustClaim(t)
+	export.Run(context.Background(), job)
+
+	stored := reload(t, job)
+	if stored.Status != models.ExportJobCompleted || stored.FinishedAt == nil || stored.Path != export.FileKey(job, export.FormatCSV) {
+		t.Fatalf("job %+v: want completed", stored)
+	}
+	if stored.Total != 1 || stored.Processed != 1 {
+		t.Errorf("processed %d of %d, want 1 of 1", stored.Processed, stored.Total)
+	}
+	if stored.LeaseToken != "" || stored.LeaseExpiresAt != nil {
+		t.Errorf("lease %q until %v kept after completion", stored.LeaseToken, stored.LeaseExpiresAt)
+	}
+
+	file, err := storage.Default.Open(context.Background(), stored.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[1][0] != "1" || records[2][3] != "Mug" {
+		t.Errorf("file rows %q", records)
+	}
+}
+
+func TestRunFailsInvalidJob(t *testing.T) {
+	testutil.Setup(t)
+
+	enqueue(t, export.OrderOptions{Format: "pdf", Layout: export.LayoutGrouped})
+	job := mustClaim(t)
+	export.Run(context.Background(), job)
+
+	stored := reload(t, job)
+	if stored.Status != models.ExportJobFailed || stored.Error != "export failed" || stored.FinishedAt == nil {
+		t.Errorf("job %+v: want failed", stored)
+	}
+
+	if _, err := storage.Default.Open(context.Background(), export.FileKey(job, "pdf")); err != storage.ErrNotFound {
+		t.Errorf("file of the failed job: %v, want ErrNotFound", err)
+	}
+}
+
+func TestRunRequeuesOnShutdown(t *testing.T) {
+	testutil.Setup(t)
+
+	enqueue(t, csvOptions)
+	job := mustClaim(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	export.Run(ctx, job)
+
+	stored := reload(t, job)
+	if stored.Status != models.ExportJobQueued || stored.Processed != 0 || stored.LeaseToken != "" {
+		t.Errorf("job %+v: want queued without a lease", stored)
+	}
+
+	// The next worker picks it up again
+	if next := mustClaim(t); next.Id != job.Id {
+		t.Errorf("claimed job %d, want %d", next.Id, job.Id)
+	}
+}
+
+func TestRunLeavesTakenOverJobAlone(t *testing.T) {
+	testutil.Setup(t)
+
+	enqueue(t, csvOptions)
+	job := mustClaim(t)
+
+	// The lease expired while this worker stalled and another worker took over
+	if err := database.DB.Model(&models.ExportJob{}).Where("id = ?", job.Id).Update("lease_token", "other").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	export.Run(context.Background(), job)
+
+	stored := reload(t, job)
+	if stored.Status != models.ExportJobRunning || stored.LeaseToken != "other" {
+		t.Errorf("job %+v: want it left running for the other worker", stored)
+	}
+
+	// Nothing was published under the job's key
+	if file, err := storage.Default.Open(context.Background(), export.FileKey(job, export.FormatCSV)); err == nil {
+		data, _ := io.ReadAll(file)
+		file.Close()
+		t.Errorf("file written by the stalled worker: %q", data)
+	}
+}
@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// unsafeFileChars matches characters replaced when building file names
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// File writes every message as an .eml file, for local development
+type File struct {
+	dir string
+}
+
+// NewFile creates a mailer writing into dir
+func NewFile(dir string) *File {
+	return &File{dir: dir}
+}
+
+// Send writes the message to <dir>/<timestamp>-<recipient>.eml
+func (f *File) Send(ctx context.Context, message Message) error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405.000000000"), unsafeFileChars.ReplaceAllString(message.To, "_"))
+
+	return os.WriteFile(filepath.Join(f.dir, name), render(message), 0o644)
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"go-ambassador/src/config"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer is implemented by every email backend
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// Default is the mailer used by the controllers
+var Default Mailer
+
+// AppURL is the base URL of the frontend, used to build links in emails
+var AppURL string
+
+// from is the sender address used by every mailer
+var from string
+
+// Setup selects the mailer described by cfg
+// appURL is the frontend base URL that emailed links point to
+func Setup(cfg config.MailConfig, appURL string) {
+	from = cfg.From
+	AppURL = strings.TrimRight(appURL, "/")
+
+	switch cfg.Driver {
+	case "smtp":
+		Default = NewSMTP(cfg)
+	case "memory":
+		Default = NewMemory()
+	default:
+		Default = NewFile(cfg.FileDir)
+	}
+}
+
+// Send delivers the message through the Default mailer
+func Send(ctx context.Context, message Message) error {
+	return Default.Send(ctx, message)
+}
+
+// Link builds a frontend URL for path with the given query parameters
+func Link(path string, query url.Values) string {
+	return AppURL + path + "?" + query.Encode()
+}
+
+// render formats the message as an RFC 5322 email with CRLF line endings
+func render(message Message) []byte {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "From: %s\r\n", from)
+	fmt.Fprintf(&builder, "To: %s\r\n", message.To)
+	fmt.Fprintf(&builder, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&builder, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
@@ -0,0 +1,126 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"go-ambassador/src/config"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// smtpDialTimeout bounds how long connecting to the SMTP server may take
+const smtpDialTimeout = 10 * time.Second
+
+// smtpSendTimeout bounds a whole delivery, from connecting to QUIT, when the
+// context has no earlier deadline
+const smtpSendTimeout = 30 * time.Second
+
+// SMTP sends email through an SMTP server
+type SMTP struct {
+	host string
+	addr string
+	auth smtp.Auth
+
+	// dialTimeout and sendTimeout are smtpDialTimeout and smtpSendTimeout,
+	// shortened by tests
+	dialTimeout time.Duration
+	sendTimeout time.Duration
+}
+
+// NewSMTP creates an SMTP mailer, authenticating when a username is configured
+func NewSMTP(cfg config.MailConfig) *SMTP {
+	mailer := &SMTP{
+		host:        cfg.SMTPHost,
+		addr:        fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		dialTimeout: smtpDialTimeout,
+		sendTimeout: smtpSendTimeout,
+	}
+
+	if cfg.SMTPUsername != "" {
+		mailer.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return mailer
+}
+
+// Send delivers the message, rejecting header injection through the recipient or subject
+// Unlike smtp.SendMail it gives up once ctx is done or sendTimeout has passed,
+// so an unresponsive server cannot block the caller
+func (s *SMTP) Send(ctx context.Context, message Message) error {
+	if strings.ContainsAny(message.To+message.Subject, "\r\n") {
+		return fmt.Errorf("mail: invalid recipient or subject")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.sendTimeout)
+	defer cancel()
+
+	dialer := net.Dialer{Timeout: s.dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("mail: %w", err)
+	}
+	defer conn.Close()
+
+	// Closing the connection once ctx is done, including when sendTimeout
+	// passes, interrupts a pending read or write
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := s.deliver(conn, message); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("mail: %w", ctx.Err())
+		}
+		return fmt.Errorf("mail: %w", err)
+	}
+
+	return nil
+}
+
+// deliver runs the SMTP conversation of smtp.SendMail over conn
+func (s *SMTP) deliver(conn net.Conn, message Message) error {
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+
+	if s.auth != nil {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(s.auth); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(render(message)); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen starts a TCP server that hands every connection to handle
+func listen(t *testing.T, handle func(conn net.Conn)) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// testSMTP returns a mailer for addr with short timeouts
+func testSMTP(addr string) *SMTP {
+	host, _, _ := net.SplitHostPort(addr)
+
+	return &SMTP{host: host, addr: addr, dialTimeout: time.Second, sendTimeout: 200 * time.Millisecond}
+}
+
+// silentServer accepts connections but never greets, like a hung mail server
+func silentServer(conn net.Conn) {
+	conn.Read(make([]byte, 1))
+}
+
+var testMessage = Message{To: "to@example.com", Subject: "Hello", Body: "Hi\n"}
+
+func TestSMTPSendsMessage(t *testing.T) {
+	received := make(chan string, 1)
+
+	addr := listen(t, func(conn net.Conn) {
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 test ESMTP")
+		var data strings.Builder
+		inData := false
+
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					received <- data.String()
+					reply("250 queued")
+					continue
+				}
+				data.WriteString(line)
+				continue
+			}
+
+			switch command := strings.ToUpper(strings.Fields(line)[0]); command {
+			case "EHLO", "HELO", "MAIL", "RCPT":
+				reply("250 ok")
+			case "DATA":
+				inData = true
+				reply("354 go ahead")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	})
+
+	from = "from@example.com"
+	if err := testSMTP(addr).Send(context.Background(), testMessage); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case data := <-received:
+		if !strings.Contains(data, "Subject: Hello\r\n") || !strings.Contains(data, "To: to@example.com\r\n") {
+			t.Errorf("unexpected message %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+}
+
+func TestSMTPTimesOutOnSilentServer(t *testing.T) {
+	addr := listen(t, silentServer)
+
+	start := time.Now()
+	err := testSMTP(addr).Send(context.Background(), testMessage)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want a deadline exceeded", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %s", elapsed)
+	}
+}
+
+func TestSMTPStopsWhenContextIsCancelled(t *testing.T) {
+	addr := listen(t, silentServer)
+
+	mailer := testSMTP(addr)
+	mailer.sendTimeout = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := mailer.Send(ctx, testMessage)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error %v, want context.Canceled", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("send took %s", elapsed)
+	}
+}
@@ -1,24 +1,48 @@
 package middlewares
 
 import (
-	"golangProject/util"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
 
 	"github.com/gofiber/fiber/v3"
 )
 
-// IsAuthenticated middleware checks if the request has a valid JWT token
-// This protects routes that require user authentication
-// Usage: app.Use(middlewares.IsAuthenticated) or app.Get("/protected", middleware, handler)
-func IsAuthenticated(c fiber.Ctx) error {
+// IsAuthenticated returns a middleware that checks the request has a valid
+// JWT token minted for scope, so an ambassador token cannot be used on admin
+// routes and vice versa, and that its session is still active server-side
+// The scope is bound to the route group rather than derived from the request
+// path, which Fiber matches case-insensitively
+// Usage: app.Group("/api/admin", middlewares.IsAuthenticated(util.ScopeAdmin))
+func IsAuthenticated(scope string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return authenticate(c, scope)
+	}
+}
+
+// authenticate implements IsAuthenticated for one request
+func authenticate(c fiber.Ctx, scope string) error {
 	// Extract JWT token from the "jwt" cookie
 	cookie := c.Cookies("jwt")
 
-	// Validate the token using the utility function
-	if _, err := util.ParseJWT(cookie); err != nil {
-		c.Status(fiber.StatusUnauthorized) // Set HTTP status to 401 Unauthorized
-		return c.JSON(fiber.Map{
-			"message": "unauthorized",
-		})
+	// Validate the token and compare its scope with the route group's
+	claims, err := util.ParseClaims(cookie)
+	if err != nil || claims.Scope != scope {
+		return apperrors.Unauthorized("unauthorized")
+	}
+
+	// The session must not have been revoked by logout or an admin
+	var active int64
+	err = database.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", claims.SessionId).
+		Count(&active).Error
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	if active == 0 {
+		return apperrors.Unauthorized("unauthorized")
 	}
 
 	// If token is valid, proceed to the next handler in the chain
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"errors"
+	"go-ambassador/src/apperrors"
+	"log"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+)
+
+// ErrorResponse is the JSON envelope of every error response
+type ErrorResponse struct {
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestId string      `json:"request_id,omitempty"`
+}
+
+// ErrorHandler renders errors returned by handlers as an ErrorResponse
+// apperrors.Error and fiber.Error keep their status and message; anything else
+// is logged and reported as a 500 without leaking its text
+// Usage: fiber.New(fiber.Config{ErrorHandler: middlewares.ErrorHandler})
+func ErrorHandler(c fiber.Ctx, err error) error {
+	response := ErrorResponse{
+		Code:      fiber.StatusInternalServerError,
+		Message:   "internal server error",
+		RequestId: requestid.FromContext(c),
+	}
+
+	var appErr *apperrors.Error
+	var fiberErr *fiber.Error
+
+	switch {
+	case errors.As(err, &appErr):
+		response.Code = appErr.Code
+		response.Message = appErr.Message
+		response.Details = appErr.Details
+	case errors.As(err, &fiberErr):
+		response.Code = fiberErr.Code
+		response.Message = fiberErr.Message
+	}
+
+	// Server side failures are logged with the request ID for correlation
+	if response.Code >= fiber.StatusInternalServerError {
+		log.Printf("request %s: %s %s: %v", response.RequestId, c.Method(), c.Path(), err)
+	}
+
+	return c.Status(response.Code).JSON(response)
+}
@@ -0,0 +1,126 @@
+package middlewares_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/middlewares"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"gorm.io/gorm"
+)
+
+// handle runs a request through an app whose only route returns err
+func handle(t *testing.T, err error) (*http.Response, middlewares.ErrorResponse) {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{ErrorHandler: middlewares.ErrorHandler})
+	app.Use(requestid.New())
+	app.Get("/", func(c fiber.Ctx) error { return err })
+
+	res, testErr := app.Test(httptest.NewRequest(http.MethodGet, "/", nil))
+	if testErr != nil {
+		t.Fatal(testErr)
+	}
+	defer res.Body.Close()
+
+	data, readErr := io.ReadAll(res.Body)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	var body middlewares.ErrorResponse
+	if err := json.Unmarshal(data, &body); err != nil {
+		t.Fatalf("body %s: %v", data, err)
+	}
+
+	return res, body
+}
+
+// captureLog collects the standard logger's output for the rest of the test
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(previous) })
+
+	return &buf
+}
+
+func TestErrorHandlerEnvelope(t *testing.T) {
+	fields := []map[string]string{{"field": "email", "message": "email is required"}}
+
+	tests := []struct {
+		name    string
+		err     error
+		code    int
+		message string
+		details interface{}
+	}{
+		{"validation", apperrors.Validation(fields), http.StatusUnprocessableEntity, "validation failed", []interface{}{map[string]interface{}{"field": "email", "message": "email is required"}}},
+		{"bad request", apperrors.BadRequest("invalid id"), http.StatusBadRequest, "invalid id", nil},
+		{"not found", apperrors.FromDB(gorm.ErrRecordNotFound, "product"), http.StatusNotFound, "product not found", nil},
+		{"conflict", apperrors.FromDB(gorm.ErrDuplicatedKey, "user"), http.StatusConflict, "user already exists", nil},
+		{"wrapped", fmt.Errorf("loading: %w", apperrors.Forbidden("no access")), http.StatusForbidden, "no access", nil},
+		{"fiber", fiber.NewError(http.StatusMethodNotAllowed, "method not allowed"), http.StatusMethodNotAllowed, "method not allowed", nil},
+		{"fiber default message", fiber.ErrRequestEntityTooLarge, http.StatusRequestEntityTooLarge, "Request Entity Too Large", nil},
+		{"unknown", errors.New("dial tcp 10.0.0.5:3306: connection refused"), http.StatusInternalServerError, "internal server error", nil},
+		{"internal", apperrors.Internal(errors.New("disk full")), http.StatusInternalServerError, "internal server error", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			res, body := handle(t, tt.err)
+
+			if res.StatusCode != tt.code || body.Code != tt.code {
+				t.Errorf("status %d, code %d, want %d", res.StatusCode, body.Code, tt.code)
+			}
+			if body.Message != tt.message {
+				t.Errorf("message %q, want %q", body.Message, tt.message)
+			}
+			if !reflect.DeepEqual(body.Details, tt.details) {
+				t.Errorf("details %#v, want %#v", body.Details, tt.details)
+			}
+			if !strings.HasPrefix(res.Header.Get(fiber.HeaderContentType), fiber.MIMEApplicationJSON) {
+				t.Errorf("content type %q", res.Header.Get(fiber.HeaderContentType))
+			}
+
+			// The request ID in the body matches the response header
+			if body.RequestId == "" || body.RequestId != res.Header.Get(fiber.HeaderXRequestID) {
+				t.Errorf("request id %q, header %q", body.RequestId, res.Header.Get(fiber.HeaderXRequestID))
+			}
+		})
+	}
+}
+
+func TestErrorHandlerLogsServerErrors(t *testing.T) {
+	logs := captureLog(t)
+
+	// Client errors are not logged
+	handle(t, apperrors.NotFound("product not found"))
+	if logs.Len() != 0 {
+		t.Errorf("client error logged: %s", logs)
+	}
+
+	// The cause of a server error is logged with the request ID but never sent
+	res, body := handle(t, errors.New("dial tcp 10.0.0.5:3306: connection refused"))
+	if !strings.Contains(logs.String(), "request "+body.RequestId+": GET /: dial tcp") {
+		t.Errorf("log %q does not name the request and cause", logs)
+	}
+
+	data, _ := json.Marshal(body)
+	if strings.Contains(string(data), "10.0.0.5") || res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("response %s leaks the cause", data)
+	}
+}
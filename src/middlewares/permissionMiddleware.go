@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"errors"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/database"
+	"go-ambassador/src/models"
+	"go-ambassador/src/util"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// IsAuthorized checks that the authenticated user's role may access the resource
+// Read requests (GET, HEAD) need view_<page> or edit_<page>, every other method
+// needs edit_<page>
+// Returns a 403 apperrors.Error naming the missing permission when access is denied
+// Usage: if err := middlewares.IsAuthorized(c, "users"); err != nil { return err }
+func IsAuthorized(c fiber.Ctx, page string) error {
+	// Identify the user from the JWT cookie
+	id, err := util.ParseJWT(c.Cookies("jwt"))
+	if err != nil {
+		return apperrors.Unauthorized("unauthorized")
+	}
+
+	// Load the user's role together with its permissions
+	var user models.User
+	err = database.DB.Preload("Role.Permissions").Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperrors.Unauthorized("unauthorized")
+	}
+	if err != nil {
+		return apperrors.Internal(err)
+	}
+
+	required := models.EditPermission(page)
+
+	// Editing implies viewing, so edit_ also grants read access
+	if c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead {
+		if user.Role.HasPermission(models.ViewPermission(page)) {
+			return nil
+		}
+		required = models.ViewPermission(page)
+	}
+
+	if user.Role.HasPermission(models.EditPermission(page)) {
+		return nil
+	}
+
+	return apperrors.Forbidden("missing permission " + required)
+}
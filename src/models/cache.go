@@ -0,0 +1,87 @@
+package models
+
+import (
+	"go-ambassador/src/cache"
+	"log"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+// Cache tags of data derived from the models
+// Writes through GORM invalidate them via the hooks below; raw SQL does not
+const (
+	ProductsCacheTag = "products"
+	OrdersCacheTag   = "orders"
+	UsersCacheTag    = "users"
+	RankingsCacheTag = "rankings"
+)
+
+// UserCacheTag is the tag of data derived from a single user
+func UserCacheTag(id uint) string {
+	return "user:" + strconv.Itoa(int(id))
+}
+
+// AfterSave runs after a product is created or updated
+func (product *Product) AfterSave(tx *gorm.DB) error {
+	return invalidate(tx, ProductsCacheTag)
+}
+
+// AfterDelete runs after a product is deleted
+func (product *Product) AfterDelete(tx *gorm.DB) error {
+	return invalidate(tx, ProductsCacheTag)
+}
+
+// AfterSave runs after an order is created or updated
+func (order *Order) AfterSave(tx *gorm.DB) error {
+	return invalidate(tx, OrdersCacheTag)
+}
+
+// AfterDelete runs after an order is deleted
+func (order *Order) AfterDelete(tx *gorm.DB) error {
+	return invalidate(tx, OrdersCacheTag)
+}
+
+// AfterSave runs after a link is created or updated
+// Link stats are tagged with the owner, so a new link shows up right away
+func (link *Link) AfterSave(tx *gorm.DB) error {
+	if link.UserId == 0 {
+		return nil
+	}
+
+	return invalidate(tx, UserCacheTag(link.UserId))
+}
+
+// AfterSave runs after a user is created or updated
+func (user *User) AfterSave(tx *gorm.DB) error {
+	return invalidate(tx, user.cacheTags()...)
+}
+
+// AfterDelete runs after a user is deleted
+func (user *User) AfterDelete(tx *gorm.DB) error {
+	return invalidate(tx, user.cacheTags()...)
+}
+
+// cacheTags lists the tags a write to the user invalidates
+// Updates through Model(&User{}).Where(...) do not know the ID, so they
+// invalidate every user
+func (user *User) cacheTags() []string {
+	if user.Id == 0 {
+		return []string{UsersCacheTag}
+	}
+
+	// Rankings show the user's name
+	return []string{UserCacheTag(user.Id), RankingsCacheTag}
+}
+
+// invalidate drops the cached data tagged with tags
+// The hooks run before a surrounding transaction commits, so a concurrent read
+// may cache the old rows again; the TTL of each entry bounds that window
+// A failure is only logged so a Redis outage does not roll back the write
+func invalidate(tx *gorm.DB, tags ...string) error {
+	if err := cache.Invalidate(tx.Statement.Context, tags...); err != nil {
+		log.Printf("cache: invalidating %v: %v", tags, err)
+	}
+
+	return nil
+}
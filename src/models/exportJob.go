@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Export job statuses
+// A job is queued until a worker claims it, then running until the file is
+// stored or generation fails
+const (
+	ExportJobQueued    = "queued"
+	ExportJobRunning   = "running"
+	ExportJobCompleted = "completed"
+	ExportJobFailed    = "failed"
+)
+
+// ExportJob is an order export generated in the background
+// Options holds the JSON encoded export options; Path is the storage key of
+// the generated file once the job has completed
+// A running job is leased to the worker holding LeaseToken until
+// LeaseExpiresAt; the worker keeps extending the lease, and a job whose lease
+// ran out is queued again for another worker
+type ExportJob struct {
+	Id         uint            `json:"id"`
+	UserId     uint            `json:"user_id" gorm:"index"`
+	Status     string          `json:"status" gorm:"size:16;default:queued;index"`
+	Options    json.RawMessage `json:"options" gorm:"type:text"`
+	Processed  int64           `json:"processed"`
+	Total      int64           `json:"total"`
+	Path       string          `json:"-"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+	StartedAt  *time.Time      `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at"`
+
+	LeaseToken     string     `json:"-" gorm:"size:32"`
+	LeaseExpiresAt *time.Time `json:"-" gorm:"index"`
+}
+
+// Progress returns the share of orders written so far, between 0 and 1
+func (job *ExportJob) Progress() float64 {
+	if job.Status == ExportJobCompleted {
+		return 1
+	}
+
+	if job.Total == 0 {
+		return 0
+	}
+
+	return min(float64(job.Processed)/float64(job.Total), 1)
+}
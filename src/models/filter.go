@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Range value types understood by RangeFilter
+const (
+	RangeNumber = "number"
+	RangeTime   = "time"
+)
+
+// FilterOptions whitelists how clients may narrow down a model's listing
+// Only the columns listed here ever reach SQL, and always as bound parameters
+type FilterOptions struct {
+	// Search lists the columns matched by ?s= (case-insensitive substring)
+	Search []string
+	// Fields lists the columns accepted as ?filter[column]=value; a comma
+	// separated value matches any of the values
+	Fields []string
+	// Ranges lists the lower/upper bound parameters, e.g. price_min/price_max
+	Ranges []RangeFilter
+}
+
+// RangeFilter maps a pair of query parameters to bounds on a column
+type RangeFilter struct {
+	Column string
+	// From is the parameter holding the inclusive lower bound
+	From string
+	// To is the parameter holding the inclusive upper bound
+	To string
+	// Type is RangeNumber or RangeTime
+	Type string
+}
+
+// filterPrefix and filterSuffix wrap the column name in ?filter[column]=
+const (
+	filterPrefix = "filter["
+	filterSuffix = "]"
+)
+
+// likeEscape escapes LIKE wildcards; it is declared explicitly in the query
+// since MySQL and SQLite disagree on the default
+const likeEscape = "!"
+
+// Filter narrows db using the s, filter[...] and range query parameters
+// Returns a 400 error for unknown filter columns or malformed values
+// Apply it before Paginate so the page totals reflect the filter
+func Filter(c fiber.Ctx, db *gorm.DB, options FilterOptions) (*gorm.DB, error) {
+	queries := c.Queries()
+
+	// Free text search across the searchable columns
+	if term := strings.TrimSpace(queries["s"]); term != "" && len(options.Search) > 0 {
+		pattern := "%" + escapeLike(strings.ToLower(term)) + "%"
+
+		conditions := make([]string, 0, len(options.Search))
+		args := make([]interface{}, 0, len(options.Search))
+		for _, column := range options.Search {
+			conditions = append(conditions, fmt.Sprintf("LOWER(%s) LIKE ? ESCAPE '%s'", quote(db, column), likeEscape))
+			args = append(args, pattern)
+		}
+
+		db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	// Exact matches on whitelisted columns, in a stable order so equal
+	// requests produce equal SQL
+	keys := make([]string, 0, len(queries))
+	for key := range queries {
+		if strings.HasPrefix(key, filterPrefix) && strings.HasSuffix(key, filterSuffix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		value := queries[key]
+
+		column := strings.TrimSuffix(strings.TrimPrefix(key, filterPrefix), filterSuffix)
+		if !slices.Contains(options.Fields, column) {
+			return nil, apperrors.BadRequest("cannot filter by " + column)
+		}
+
+		if value == "" {
+			continue
+		}
+
+		values := strings.Split(value, ",")
+		if len(values) == 1 {
+			db = db.Where(fmt.Sprintf("%s = ?", quote(db, column)), value)
+		} else {
+			db = db.Where(fmt.Sprintf("%s IN ?", quote(db, column)), values)
+		}
+	}
+
+	// Lower and upper bounds
+	for _, filter := range options.Ranges {
+		if raw := queries[filter.From]; raw != "" {
+			value, _, err := parseRangeValue(filter.Type, raw, false)
+			if err != nil {
+				return nil, apperrors.BadRequest(fmt.Sprintf("invalid %s: %v", filter.From, err))
+			}
+			db = db.Where(fmt.Sprintf("%s >= ?", quote(db, filter.Column)), value)
+		}
+
+		if raw := queries[filter.To]; raw != "" {
+			value, exclusive, err := parseRangeValue(filter.Type, raw, true)
+			if err != nil {
+				return nil, apperrors.BadRequest(fmt.Sprintf("invalid %s: %v", filter.To, err))
+			}
+
+			operator := "<="
+			if exclusive {
+				operator = "<"
+			}
+			db = db.Where(fmt.Sprintf("%s %s ?", quote(db, filter.Column), operator), value)
+		}
+	}
+
+	return db, nil
+}
+
+// parseRangeValue converts a range parameter to a number or time
+// Times are parsed by ParseTimeBound
+func parseRangeValue(kind string, raw string, upper bool) (value interface{}, exclusive bool, err error) {
+	switch kind {
+	case RangeNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("expected a number")
+		}
+		return number, false, nil
+	case RangeTime:
+		t, exclusive, err := ParseTimeBound(raw, upper)
+		if err != nil {
+			return nil, false, err
+		}
+		return t, exclusive, nil
+	}
+
+	return nil, false, fmt.Errorf("unsupported range type %q", kind)
+}
+
+// ParseTimeBound parses the lower or upper bound of a time range
+// It accepts RFC 3339 or a plain YYYY-MM-DD date; a date-only upper bound
+// covers the whole day and is returned as the next midnight with exclusive set
+func ParseTimeBound(raw string, upper bool) (t time.Time, exclusive bool, err error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, false, nil
+	}
+
+	day, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("expected YYYY-MM-DD or RFC 3339")
+	}
+
+	if upper {
+		return day.AddDate(0, 0, 1), true, nil
+	}
+	return day, false, nil
+}
+
+// escapeLike escapes the LIKE wildcards % and _ and the escape character itself
+func escapeLike(term string) string {
+	replacer := strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+	return replacer.Replace(term)
+}
@@ -1 +1,17 @@
 package models
+
+// Link is a tracked referral link owned by an ambassador
+// The Code is shared publicly and resolves to the ambassador and the selected products
+type Link struct {
+	Id       uint      `json:"id"`
+	Code     string    `json:"code" gorm:"size:32;uniqueIndex"`
+	UserId   uint      `json:"user_id"`
+	User     User      `json:"user" gorm:"foreignKey:UserId"`
+	Products []Product `json:"products" gorm:"many2many:link_products"`
+}
+
+// LinkPageOptions configures pagination of link listings
+var LinkPageOptions = PageOptions{
+	Sortable: []string{"code"},
+	Preload:  []string{"Products"},
+}
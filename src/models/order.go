@@ -1 +1,170 @@
 package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AmbassadorCommission is the share of each line item paid out to the ambassador
+// The remainder of the line total is recorded as admin revenue
+const AmbassadorCommission = 0.1
+
+// Order statuses
+// An order starts pending and becomes paid once the payment provider confirms it
+const (
+	OrderStatusPending           = "pending"
+	OrderStatusPaid              = "paid"
+	OrderStatusCancelled         = "cancelled"
+	OrderStatusRefunded          = "refunded"
+	OrderStatusPartiallyRefunded = "partially_refunded"
+)
+
+// OrderStatuses lists every order status
+var OrderStatuses = []string{
+	OrderStatusPending,
+	OrderStatusPaid,
+	OrderStatusCancelled,
+	OrderStatusRefunded,
+	OrderStatusPartiallyRefunded,
+}
+
+// orderTransitions lists the statuses each status may move to
+// Cancelled and refunded orders are final
+var orderTransitions = map[string][]string{
+	OrderStatusPending:           {OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusPaid:              {OrderStatusRefunded, OrderStatusPartiallyRefunded},
+	OrderStatusPartiallyRefunded: {OrderStatusPartiallyRefunded, OrderStatusRefunded},
+}
+
+// TransitionError is returned when an order cannot move to the requested status
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("cannot transition order from %s to %s", e.From, e.To)
+}
+
+// Order is a purchase placed by a buyer through an ambassador's link
+// Buyer details are stored on the order itself since buyers do not need an account
+type Order struct {
+	Id              uint        `json:"id"`
+	TransactionId   string      `json:"transaction_id" gorm:"null"`
+	UserId          uint        `json:"user_id"`
+	Code            string      `json:"code"`
+	AmbassadorEmail string      `json:"ambassador_email"`
+	FirstName       string      `json:"first_name"`
+	LastName        string      `json:"last_name"`
+	Email           string      `json:"email"`
+	Address         string      `json:"address" gorm:"null"`
+	City            string      `json:"city" gorm:"null"`
+	Country         string      `json:"country" gorm:"null"`
+	Zip             string      `json:"zip" gorm:"null"`
+	PaymentId       string      `json:"-" gorm:"null"`
+	Status          string      `json:"status" gorm:"size:32;default:pending;index"`
+	RefundedAmount  float64     `json:"refunded_amount" gorm:"default:0"`
+	CreateAt        time.Time   `json:"create_at" gorm:"autoCreateTime"`
+	PaidAt          *time.Time  `json:"paid_at"`
+	CancelledAt     *time.Time  `json:"cancelled_at"`
+	RefundedAt      *time.Time  `json:"refunded_at"`
+	OrderItems      []OrderItem `json:"order_items" gorm:"foreignKey:OrderId"`
+}
+
+// OrderItem is a single product line of an Order
+// ProductTitle and Price are snapshots taken at checkout so later product edits
+// do not change historical orders; ProductId identifies the product in reports
+// even after it is renamed, and is zero for items recorded before it existed
+type OrderItem struct {
+	Id                uint    `json:"id"`
+	OrderId           uint    `json:"order_id"`
+	ProductId         uint    `json:"product_id" gorm:"index"`
+	ProductTitle      string  `json:"product_title"`
+	Price             float64 `json:"price"`
+	Quantity          uint    `json:"quantity"`
+	AdminRevenue      float64 `json:"admin_revenue"`
+	AmbassadorRevenue float64 `json:"ambassador_revenue"`
+}
+
+// NewOrderItem builds a line item for the product and splits its total
+// between admin and ambassador revenue
+func NewOrderItem(product Product, quantity uint) OrderItem {
+	total := product.Price * float64(quantity)
+
+	return OrderItem{
+		ProductId:         product.Id,
+		ProductTitle:      product.Title,
+		Price:             product.Price,
+		Quantity:          quantity,
+		AdminRevenue:      total * (1 - AmbassadorCommission),
+		AmbassadorRevenue: total * AmbassadorCommission,
+	}
+}
+
+// CanTransition reports whether the order may move to the given status
+func (order *Order) CanTransition(to string) bool {
+	for _, allowed := range orderTransitions[order.Status] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition moves the order to the given status and stamps the matching timestamp
+// Returns a *TransitionError if the move is not allowed; the order is left unchanged
+func (order *Order) Transition(to string) error {
+	if !order.CanTransition(to) {
+		return &TransitionError{From: order.Status, To: to}
+	}
+
+	now := time.Now()
+
+	switch to {
+	case OrderStatusPaid:
+		order.PaidAt = &now
+	case OrderStatusCancelled:
+		order.CancelledAt = &now
+	case OrderStatusRefunded, OrderStatusPartiallyRefunded:
+		order.RefundedAt = &now
+	}
+
+	order.Status = to
+
+	return nil
+}
+
+// Total returns the sum of price times quantity over all items
+// OrderItems must be loaded
+func (order *Order) Total() float64 {
+	var total float64
+	for _, item := range order.OrderItems {
+		total += item.Price * float64(item.Quantity)
+	}
+	return total
+}
+
+// AmbassadorRevenue returns the ambassador's commission over all items
+// OrderItems must be loaded
+func (order *Order) AmbassadorRevenue() float64 {
+	var revenue float64
+	for _, item := range order.OrderItems {
+		revenue += item.AmbassadorRevenue
+	}
+	return revenue
+}
+
+// OrderPageOptions configures pagination of order listings
+// Orders are listed newest first and support keyset pagination on large tables
+var OrderPageOptions = PageOptions{
+	Sortable:    []string{"create_at", "status", "email"},
+	DefaultSort: "-id",
+	Preload:     []string{"OrderItems"},
+	Filters: FilterOptions{
+		Search: []string{"email", "first_name", "last_name", "code", "ambassador_email"},
+		Fields: []string{"status", "email", "code", "user_id"},
+		Ranges: []RangeFilter{
+			{Column: "create_at", From: "created_from", To: "created_to", Type: RangeTime},
+		},
+	},
+}
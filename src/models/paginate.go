@@ -1,41 +1,318 @@
 package models
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-ambassador/src/apperrors"
+	"go-ambassador/src/util"
 	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gofiber/fiber/v3"
 	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
-// Paginate provides a generic pagination solution for any Entity
-// This function eliminates code duplication for paginated endpoints
-// Parameters:
-//   - db: GORM database connection
-//   - entity: Entity interface implementation (User, Product, etc.)
-//   - page: current page number for pagination
-//
-// Returns:
-//   - fiber.Map: standardized pagination response with data and metadata
-func Paginate(db *gorm.DB, entity Entity, page int) fiber.Map {
-	// Set the number of records to display per page
-	limit := 5
-
-	// Calculate the offset for database query
-	offset := (page - 1) * limit
-
-	// Retrieve paginated data using the entity's Take method
-	data := entity.Take(db, limit, offset)
-
-	// Get total record count using the entity's Count method
-	total := entity.Count(db)
-
-	// Return standardized pagination response
-	return fiber.Map{
-		"data": data,
-		"meta": fiber.Map{
-			"total":     total,
-			"page":      page,
-			"last_page": math.Ceil(float64(total) / float64(limit)), // Calculate the last page number for pagination navigation
-		},
+// DefaultPerPage is the page size used when the request does not ask for one
+const DefaultPerPage = 5
+
+// MaxPerPage is the largest page size a client may request
+const MaxPerPage = 100
+
+// MaxPage is the deepest page offset pagination serves; each page further
+// makes the database skip more rows, so deeper listings must use the cursor
+const MaxPage = 1000
+
+// PageRequest describes which slice of a listing the client wants
+type PageRequest struct {
+	// Page is the 1-based page number; ignored in cursor mode
+	Page int
+	// PerPage is the number of records per page
+	PerPage int
+	// Sort is a column name, prefixed with "-" for descending order
+	Sort string
+	// Cursor is the next_cursor returned with the previous page
+	Cursor string
+	// CursorMode selects keyset pagination; an empty Cursor then means the first page
+	CursorMode bool
+}
+
+// PageOptions configures how a model may be paginated
+type PageOptions struct {
+	// Sortable whitelists the columns clients may sort by
+	Sortable []string
+	// DefaultSort is used when the request does not specify a sort, e.g. "-id"
+	DefaultSort string
+	// Preload lists associations loaded for every record
+	Preload []string
+	// Filters whitelists the search, filter and range parameters
+	Filters FilterOptions
+}
+
+// PageMeta describes the position of a page within the listing
+// Offset pagination fills Total, Page and LastPage; cursor pagination fills
+// NextCursor instead and skips the COUNT query
+type PageMeta struct {
+	Total      *int64 `json:"total,omitempty"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	LastPage   *int   `json:"last_page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page is a single page of records together with its metadata
+type Page[T any] struct {
+	Data []T      `json:"data"`
+	Meta PageMeta `json:"meta"`
+}
+
+// NewPageRequest reads page, per_page, sort and cursor from the query string
+// Returns a 400 error if page or per_page is not a number or out of bounds,
+// and a 422 error if page is beyond MaxPage
+// A cursor parameter, even an empty one, switches to keyset pagination
+func NewPageRequest(c fiber.Ctx) (PageRequest, error) {
+	request := PageRequest{
+		Page:    1,
+		PerPage: DefaultPerPage,
+		Sort:    c.Query("sort"),
+		Cursor:  c.Query("cursor"),
+	}
+
+	// An empty ?cursor= asks for the first page in keyset mode
+	request.CursorMode = request.Cursor != "" || c.Request().URI().QueryArgs().Has("cursor")
+
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return request, apperrors.BadRequest("page must be a positive number")
+		}
+		if page > MaxPage {
+			return request, apperrors.Validation([]util.FieldError{{
+				Field:   "page",
+				Message: fmt.Sprintf("must be at most %d, use cursor pagination for deeper pages", MaxPage),
+			}})
+		}
+		request.Page = page
+	}
+
+	if raw := c.Query("per_page"); raw != "" {
+		perPage, err := strconv.Atoi(raw)
+		if err != nil || perPage < 1 || perPage > MaxPerPage {
+			return request, apperrors.BadRequest(fmt.Sprintf("per_page must be between 1 and %d", MaxPerPage))
+		}
+		request.PerPage = perPage
+	}
+
+	return request, nil
+}
+
+// Paginate returns one page of T from db, which may already carry conditions
+// Sorting is restricted to options.Sortable; the primary key is always added
+// as a tie-breaker so pages are stable
+func Paginate[T any](db *gorm.DB, request PageRequest, options PageOptions) (Page[T], error) {
+	page := Page[T]{Data: []T{}}
+
+	if request.PerPage < 1 || request.PerPage > MaxPerPage {
+		request.PerPage = DefaultPerPage
+	}
+	if request.Page < 1 {
+		request.Page = 1
+	}
+	if request.Page > MaxPage {
+		request.Page = MaxPage
 	}
+	page.Meta.PerPage = request.PerPage
+
+	modelSchema, err := parseSchema[T](db)
+	if err != nil {
+		return page, err
+	}
+
+	primaryKey := modelSchema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		return page, fmt.Errorf("paginate %s: model has no primary key", modelSchema.Name)
+	}
+
+	// Resolve the sort column against the whitelist
+	sort := request.Sort
+	if sort == "" {
+		sort = options.DefaultSort
+	}
+	if sort == "" {
+		sort = primaryKey.DBName
+	}
+
+	desc := strings.HasPrefix(sort, "-")
+	column := strings.TrimPrefix(sort, "-")
+
+	if column != primaryKey.DBName && !slices.Contains(options.Sortable, column) {
+		return page, apperrors.BadRequest("cannot sort by " + column)
+	}
+
+	sortField := modelSchema.LookUpField(column)
+	if sortField == nil {
+		return page, fmt.Errorf("paginate %s: unknown sort column %s", modelSchema.Name, column)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	// Session makes the scope reusable for both the data and the count query
+	scoped := db.Model(new(T)).Session(&gorm.Session{})
+
+	query := scoped
+	for _, association := range options.Preload {
+		query = query.Preload(association)
+	}
+
+	order := fmt.Sprintf("%s %s", quote(db, column), direction)
+	if column != primaryKey.DBName {
+		order += fmt.Sprintf(", %s %s", quote(db, primaryKey.DBName), direction)
+	}
+	query = query.Order(order)
+
+	if request.CursorMode {
+		return paginateCursor(query, page, request, sortField, primaryKey, desc)
+	}
+
+	// Offset pagination
+	var total int64
+	if err := scoped.Count(&total).Error; err != nil {
+		return page, err
+	}
+
+	offset := (request.Page - 1) * request.PerPage
+	if err := query.Offset(offset).Limit(request.PerPage).Find(&page.Data).Error; err != nil {
+		return page, err
+	}
+
+	lastPage := int(math.Ceil(float64(total) / float64(request.PerPage)))
+
+	page.Meta.Total = &total
+	page.Meta.Page = request.Page
+	page.Meta.LastPage = &lastPage
+
+	return page, nil
+}
+
+// pageCursor is the decoded form of a keyset cursor: the sort value and
+// primary key of the last record on the previous page
+type pageCursor struct {
+	Value json.RawMessage `json:"v"`
+	Id    json.RawMessage `json:"id"`
+}
+
+// paginateCursor returns the page after request.Cursor using keyset conditions
+// instead of OFFSET, so deep pages cost the same as the first one
+func paginateCursor[T any](query *gorm.DB, page Page[T], request PageRequest, sortField *schema.Field, primaryKey *schema.Field, desc bool) (Page[T], error) {
+	if request.Cursor != "" {
+		value, id, err := decodeCursor(request.Cursor, sortField, primaryKey)
+		if err != nil {
+			return page, apperrors.BadRequest("invalid cursor")
+		}
+
+		operator := ">"
+		if desc {
+			operator = "<"
+		}
+
+		sortColumn := quote(query, sortField.DBName)
+		idColumn := quote(query, primaryKey.DBName)
+
+		if sortField == primaryKey {
+			query = query.Where(fmt.Sprintf("%s %s ?", idColumn, operator), id)
+		} else {
+			query = query.Where(
+				fmt.Sprintf("((%s %s ?) OR (%s = ? AND %s %s ?))", sortColumn, operator, sortColumn, idColumn, operator),
+				value, value, id,
+			)
+		}
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	if err := query.Limit(request.PerPage + 1).Find(&page.Data).Error; err != nil {
+		return page, err
+	}
+
+	if len(page.Data) > request.PerPage {
+		page.Data = page.Data[:request.PerPage]
+
+		cursor, err := encodeCursor(query, &page.Data[len(page.Data)-1], sortField, primaryKey)
+		if err != nil {
+			return page, err
+		}
+		page.Meta.NextCursor = cursor
+	}
+
+	return page, nil
+}
+
+// encodeCursor builds the cursor pointing just past record
+func encodeCursor(db *gorm.DB, record interface{}, sortField *schema.Field, primaryKey *schema.Field) (string, error) {
+	rv := reflect.ValueOf(record)
+	ctx := db.Statement.Context
+
+	value, _ := sortField.ValueOf(ctx, rv)
+	id, _ := primaryKey.ValueOf(ctx, rv)
+
+	rawValue, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	rawId, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(pageCursor{Value: rawValue, Id: rawId})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(encoded), nil
+}
+
+// decodeCursor parses a cursor into values typed like the sort and primary key fields
+func decodeCursor(cursor string, sortField *schema.Field, primaryKey *schema.Field) (interface{}, interface{}, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw pageCursor
+	if err := json.Unmarshal(decoded, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	value := reflect.New(sortField.FieldType)
+	if err := json.Unmarshal(raw.Value, value.Interface()); err != nil {
+		return nil, nil, err
+	}
+
+	id := reflect.New(primaryKey.FieldType)
+	if err := json.Unmarshal(raw.Id, id.Interface()); err != nil {
+		return nil, nil, err
+	}
+
+	return value.Elem().Interface(), id.Elem().Interface(), nil
+}
+
+// schemaCache stores parsed model schemas between requests
+var schemaCache = &sync.Map{}
+
+// parseSchema returns the gorm schema of T
+func parseSchema[T any](db *gorm.DB) (*schema.Schema, error) {
+	return schema.Parse(new(T), schemaCache, db.NamingStrategy)
+}
+
+// quote quotes a column name for the connected dialect
+func quote(db *gorm.DB, column string) string {
+	return db.Statement.Quote(column)
 }
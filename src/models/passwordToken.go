@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Password token purposes
+const (
+	PasswordTokenReset  = "reset"
+	PasswordTokenInvite = "invite"
+)
+
+// PasswordToken is a single-use token letting a user set a new password
+// It is issued by the forgot password flow and by admin invitations
+// Only the SHA-256 of the token is stored
+type PasswordToken struct {
+	Id        uint       `json:"id"`
+	UserId    uint       `json:"user_id" gorm:"index"`
+	Purpose   string     `json:"purpose" gorm:"size:16"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
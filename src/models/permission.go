@@ -0,0 +1,20 @@
+package models
+
+// PermissionResources are the admin resources guarded by view_ and edit_ permissions
+var PermissionResources = []string{"users", "roles", "products", "orders"}
+
+// Permission is a named capability such as view_users or edit_products
+type Permission struct {
+	Id   uint   `json:"id"`
+	Name string `json:"name" gorm:"size:64;uniqueIndex"`
+}
+
+// ViewPermission returns the name of the permission needed to read a resource
+func ViewPermission(resource string) string {
+	return "view_" + resource
+}
+
+// EditPermission returns the name of the permission needed to modify a resource
+func EditPermission(resource string) string {
+	return "edit_" + resource
+}
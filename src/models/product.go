@@ -1 +1,22 @@
 package models
+
+// Product represents an item in the catalog that ambassadors can promote
+type Product struct {
+	Id          uint    `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Image       string  `json:"image"`
+	Price       float64 `json:"price"`
+}
+
+// ProductPageOptions configures pagination of product listings
+var ProductPageOptions = PageOptions{
+	Sortable: []string{"title", "price"},
+	Filters: FilterOptions{
+		Search: []string{"title", "description"},
+		Fields: []string{"title"},
+		Ranges: []RangeFilter{
+			{Column: "price", From: "price_min", To: "price_max", Type: RangeNumber},
+		},
+	},
+}
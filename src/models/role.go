@@ -0,0 +1,19 @@
+package models
+
+// Role groups a set of permissions that can be assigned to users
+type Role struct {
+	Id          uint         `json:"id"`
+	Name        string       `json:"name" gorm:"size:64;uniqueIndex"`
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+}
+
+// HasPermission reports whether the role grants the named permission
+// Permissions must be loaded
+func (role *Role) HasPermission(name string) bool {
+	for _, permission := range role.Permissions {
+		if permission.Name == name {
+			return true
+		}
+	}
+	return false
+}
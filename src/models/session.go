@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is one refresh token of a login session
+// Every refresh rotates the token: the used row is marked RotatedAt and a new
+// row is added to the same family. Presenting a rotated token again means it
+// was stolen, so the whole family is revoked
+type Session struct {
+	Id        uint       `json:"id"`
+	UserId    uint       `json:"user_id" gorm:"index"`
+	FamilyId  string     `json:"family_id" gorm:"size:64;index"`
+	Scope     string     `json:"scope" gorm:"size:16"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether the refresh token can still be exchanged
+func (session *Session) Active(now time.Time) bool {
+	return session.RotatedAt == nil && session.RevokedAt == nil && now.Before(session.ExpiresAt)
+}
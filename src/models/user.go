@@ -1 +1,58 @@
 package models
+
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User represents an account on the platform
+// Admins and ambassadors share this table and are distinguished by IsAmbassador
+type User struct {
+	Id           uint   `json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email" gorm:"unique"`
+	Password     []byte `json:"-"`
+	IsAmbassador bool   `json:"-"`
+	RoleId       uint   `json:"role_id"`
+	Role         Role   `json:"role" gorm:"foreignKey:RoleId"`
+
+	// EmailVerifiedAt is set once the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+
+	// CreatedAt is NULL for accounts created before it was recorded, which is
+	// how migration 0002 tells them apart from later sign-ups
+	CreatedAt *time.Time `json:"-"`
+}
+
+// Name returns the user's full name
+func (user *User) Name() string {
+	return user.FirstName + " " + user.LastName
+}
+
+// IsVerified reports whether the user has confirmed their email address
+func (user *User) IsVerified() bool {
+	return user.EmailVerifiedAt != nil
+}
+
+// SetPassword hashes the plain text password with bcrypt and stores it on the user
+func (user *User) SetPassword(password string) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(password), 14)
+	user.Password = hashedPassword
+}
+
+// ComparePassword checks a plain text password against the stored bcrypt hash
+// Returns nil when the password matches
+func (user *User) ComparePassword(password string) error {
+	return bcrypt.CompareHashAndPassword(user.Password, []byte(password))
+}
+
+// UserPageOptions configures pagination of user listings
+var UserPageOptions = PageOptions{
+	Sortable: []string{"first_name", "last_name", "email"},
+	Filters: FilterOptions{
+		Search: []string{"first_name", "last_name", "email"},
+		Fields: []string{"email", "role_id"},
+	},
+}
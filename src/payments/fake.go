@@ -0,0 +1,126 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Prefixes of the IDs issued by Fake
+const (
+	fakeSessionPrefix = "cs_fake_"
+	fakePaymentPrefix = "pi_fake_"
+)
+
+// Fake is an in-process Provider for tests and local development
+// Sessions are considered paid on confirmation unless declined with Decline
+// The session total is encoded in the session and payment IDs, so sessions
+// created before a restart can still be confirmed and refunded
+type Fake struct {
+	mu       sync.Mutex
+	declined map[string]bool
+	refunds  map[string]int64
+}
+
+// NewFake creates an empty fake provider
+func NewFake() *Fake {
+	return &Fake{
+		declined: map[string]bool{},
+		refunds:  map[string]int64{},
+	}
+}
+
+// CreateCheckoutSession returns a new session and a local checkout URL
+func (f *Fake) CreateCheckoutSession(ctx context.Context, params SessionParams) (*Session, error) {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	id := fakeId(fakeSessionPrefix, params.Total(), hex.EncodeToString(suffix))
+
+	// There is no hosted page, so send the buyer straight to the success URL
+	url := strings.ReplaceAll(params.SuccessURL, "{CHECKOUT_SESSION_ID}", id)
+
+	return &Session{Id: id, URL: url}, nil
+}
+
+// Confirm reports the session as paid unless it was declined
+func (f *Fake) Confirm(ctx context.Context, sessionId string) (*Confirmation, error) {
+	total, suffix, ok := parseFakeId(fakeSessionPrefix, sessionId)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return &Confirmation{
+		SessionId:   sessionId,
+		PaymentId:   fakeId(fakePaymentPrefix, total, suffix),
+		Paid:        !f.declined[sessionId],
+		AmountTotal: total,
+	}, nil
+}
+
+// Refund records a refund, rejecting amounts above what is left on the payment
+// Refunds are only remembered until the process exits
+func (f *Fake) Refund(ctx context.Context, paymentId string, amount int64) (*Refund, error) {
+	total, _, ok := parseFakeId(fakePaymentPrefix, paymentId)
+	if !ok {
+		return nil, fmt.Errorf("fake: payment %s not found", paymentId)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if amount <= 0 || f.refunds[paymentId]+amount > total {
+		return nil, fmt.Errorf("fake: invalid refund amount %d", amount)
+	}
+
+	f.refunds[paymentId] += amount
+
+	return &Refund{
+		Id:        fmt.Sprintf("re_fake_%s_%d", paymentId, f.refunds[paymentId]),
+		PaymentId: paymentId,
+		Amount:    amount,
+		Status:    "succeeded",
+	}, nil
+}
+
+// Decline marks a session as unpaid so Confirm reports Paid false
+func (f *Fake) Decline(sessionId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.declined[sessionId] = true
+}
+
+// fakeId builds an ID carrying the total, e.g. cs_fake_4500_1f2e3d4c5b6a7980
+func fakeId(prefix string, total int64, suffix string) string {
+	return prefix + strconv.FormatInt(total, 10) + "_" + suffix
+}
+
+// parseFakeId returns the total and suffix of an ID built by fakeId
+func parseFakeId(prefix string, id string) (int64, string, bool) {
+	rest, ok := strings.CutPrefix(id, prefix)
+	if !ok {
+		return 0, "", false
+	}
+
+	rawTotal, suffix, ok := strings.Cut(rest, "_")
+	if !ok || suffix == "" {
+		return 0, "", false
+	}
+
+	total, err := strconv.ParseInt(rawTotal, 10, 64)
+	if err != nil || total < 0 {
+		return 0, "", false
+	}
+
+	return total, suffix, true
+}
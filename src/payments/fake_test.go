@@ -0,0 +1,71 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFakeConfirmAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	params := SessionParams{
+		LineItems:  []LineItem{{Name: "Mug", UnitAmount: 1250, Quantity: 2}},
+		SuccessURL: "http://checkout/success?source={CHECKOUT_SESSION_ID}",
+	}
+
+	session, err := NewFake().CreateCheckoutSession(ctx, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(session.URL, "source="+session.Id) {
+		t.Errorf("url %q does not carry the session ID", session.URL)
+	}
+
+	// A new instance stands in for the provider after a restart
+	restarted := NewFake()
+
+	confirmation, err := restarted.Confirm(ctx, session.Id)
+	if err != nil {
+		t.Fatalf("confirming after restart: %v", err)
+	}
+	if !confirmation.Paid || confirmation.AmountTotal != 2500 {
+		t.Errorf("confirmation %+v: want paid 2500", confirmation)
+	}
+
+	if _, err := restarted.Refund(ctx, confirmation.PaymentId, 1000); err != nil {
+		t.Errorf("refunding after restart: %v", err)
+	}
+	if _, err := restarted.Refund(ctx, confirmation.PaymentId, 1501); err == nil {
+		t.Error("refunding more than the remaining total succeeded")
+	}
+}
+
+func TestFakeDecline(t *testing.T) {
+	ctx := context.Background()
+	fake := NewFake()
+
+	session, err := fake.CreateCheckoutSession(ctx, SessionParams{LineItems: []LineItem{{UnitAmount: 100, Quantity: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.Decline(session.Id)
+
+	confirmation, err := fake.Confirm(ctx, session.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmation.Paid {
+		t.Error("declined session reported paid")
+	}
+}
+
+func TestFakeUnknownSession(t *testing.T) {
+	for _, id := range []string{"", "cs_test_123", "cs_fake_", "cs_fake_abc_def", "cs_fake_100_"} {
+		if _, err := NewFake().Confirm(context.Background(), id); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Confirm(%q) = %v, want ErrSessionNotFound", id, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"go-ambassador/src/config"
+	"log"
+	"strings"
+)
+
+// Currency is the ISO currency code used for all checkout sessions
+const Currency = "usd"
+
+// ErrSessionNotFound is returned when a provider does not know the session ID
+var ErrSessionNotFound = errors.New("checkout session not found")
+
+// Provider is implemented by every payment backend the checkout flow can use
+type Provider interface {
+	// CreateCheckoutSession starts a hosted checkout for the given line items
+	CreateCheckoutSession(ctx context.Context, params SessionParams) (*Session, error)
+
+	// Confirm looks up a checkout session and reports whether it has been paid
+	Confirm(ctx context.Context, sessionId string) (*Confirmation, error)
+
+	// Refund returns amount (in the smallest currency unit) of a captured payment
+	Refund(ctx context.Context, paymentId string, amount int64) (*Refund, error)
+}
+
+// LineItem is a single product line shown on the hosted checkout page
+// UnitAmount is in the smallest currency unit (cents)
+type LineItem struct {
+	Name       string
+	UnitAmount int64
+	Quantity   int64
+}
+
+// SessionParams describes the checkout session to create
+type SessionParams struct {
+	// Reference identifies the order on the provider side
+	Reference  string
+	LineItems  []LineItem
+	SuccessURL string
+	CancelURL  string
+}
+
+// Session is a created checkout session
+type Session struct {
+	Id  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// Confirmation is the payment state of a checkout session
+type Confirmation struct {
+	SessionId   string
+	PaymentId   string
+	Paid        bool
+	AmountTotal int64
+}
+
+// Refund is the result of a refund request
+type Refund struct {
+	Id        string
+	PaymentId string
+	Amount    int64
+	Status    string
+}
+
+// Default is the provider used by the checkout controllers
+var Default Provider
+
+// CheckoutURL is the base URL of the checkout frontend
+// Buyers are redirected back to it after paying or cancelling
+var CheckoutURL string
+
+// Setup selects the payment provider described by cfg
+// The fake is only used when explicitly configured; config validation rejects
+// the Stripe driver without a secret key, so a misconfigured deploy cannot
+// fall back to marking orders paid without charging
+func Setup(cfg config.PaymentsConfig) {
+	CheckoutURL = strings.TrimRight(cfg.CheckoutURL, "/")
+
+	switch cfg.Driver {
+	case "fake":
+		log.Println("payments: using the fake provider, orders are marked paid without charging")
+		Default = NewFake()
+	default:
+		Default = NewStripe(cfg.StripeSecretKey, cfg.StripeBaseURL)
+	}
+}
+
+// Total returns the sum of all line items in the smallest currency unit
+func (params SessionParams) Total() int64 {
+	var total int64
+	for _, item := range params.LineItems {
+		total += item.UnitAmount * item.Quantity
+	}
+	return total
+}
@@ -0,0 +1,161 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStripeBaseURL is the production Stripe API endpoint
+const DefaultStripeBaseURL = "https://api.stripe.com"
+
+// Stripe is a Provider speaking the Stripe Checkout HTTP API
+// BaseURL can point at a test double such as an httptest server
+type Stripe struct {
+	SecretKey  string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// StripeError is returned when the Stripe API responds with a non-2xx status
+type StripeError struct {
+	StatusCode int
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+}
+
+func (e *StripeError) Error() string {
+	return fmt.Sprintf("stripe: %s (status %d, type %s)", e.Message, e.StatusCode, e.Type)
+}
+
+// stripeSession is the subset of the Stripe checkout session object we use
+type stripeSession struct {
+	Id            string `json:"id"`
+	URL           string `json:"url"`
+	PaymentStatus string `json:"payment_status"`
+	PaymentIntent string `json:"payment_intent"`
+	AmountTotal   int64  `json:"amount_total"`
+}
+
+// stripeRefund is the subset of the Stripe refund object we use
+type stripeRefund struct {
+	Id            string `json:"id"`
+	PaymentIntent string `json:"payment_intent"`
+	Amount        int64  `json:"amount"`
+	Status        string `json:"status"`
+}
+
+// NewStripe creates a Stripe adapter
+// An empty baseURL falls back to DefaultStripeBaseURL
+func NewStripe(secretKey string, baseURL string) *Stripe {
+	if baseURL == "" {
+		baseURL = DefaultStripeBaseURL
+	}
+
+	return &Stripe{
+		SecretKey:  secretKey,
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// CreateCheckoutSession creates a Stripe Checkout session in payment mode
+func (s *Stripe) CreateCheckoutSession(ctx context.Context, params SessionParams) (*Session, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", params.SuccessURL)
+	form.Set("cancel_url", params.CancelURL)
+	form.Set("client_reference_id", params.Reference)
+
+	// Stripe expects nested parameters in bracket notation
+	for i, item := range params.LineItems {
+		prefix := fmt.Sprintf("line_items[%d]", i)
+		form.Set(prefix+"[price_data][currency]", Currency)
+		form.Set(prefix+"[price_data][product_data][name]", item.Name)
+		form.Set(prefix+"[price_data][unit_amount]", strconv.FormatInt(item.UnitAmount, 10))
+		form.Set(prefix+"[quantity]", strconv.FormatInt(item.Quantity, 10))
+	}
+
+	var session stripeSession
+	if err := s.do(ctx, http.MethodPost, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+
+	return &Session{Id: session.Id, URL: session.URL}, nil
+}
+
+// Confirm retrieves the checkout session and reports whether it has been paid
+func (s *Stripe) Confirm(ctx context.Context, sessionId string) (*Confirmation, error) {
+	var session stripeSession
+	if err := s.do(ctx, http.MethodGet, "/v1/checkout/sessions/"+url.PathEscape(sessionId), nil, &session); err != nil {
+		return nil, err
+	}
+
+	return &Confirmation{
+		SessionId:   session.Id,
+		PaymentId:   session.PaymentIntent,
+		Paid:        session.PaymentStatus == "paid",
+		AmountTotal: session.AmountTotal,
+	}, nil
+}
+
+// Refund refunds amount of the payment intent
+func (s *Stripe) Refund(ctx context.Context, paymentId string, amount int64) (*Refund, error) {
+	form := url.Values{}
+	form.Set("payment_intent", paymentId)
+	form.Set("amount", strconv.FormatInt(amount, 10))
+
+	var refund stripeRefund
+	if err := s.do(ctx, http.MethodPost, "/v1/refunds", form, &refund); err != nil {
+		return nil, err
+	}
+
+	return &Refund{
+		Id:        refund.Id,
+		PaymentId: refund.PaymentIntent,
+		Amount:    refund.Amount,
+		Status:    refund.Status,
+	}, nil
+}
+
+// do sends an authenticated form request and decodes the JSON response into out
+func (s *Stripe) do(ctx context.Context, method string, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.SecretKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	res, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Stripe wraps errors as {"error": {...}}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var envelope struct {
+			Error StripeError `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&envelope)
+		envelope.Error.StatusCode = res.StatusCode
+
+		if res.StatusCode == http.StatusNotFound && envelope.Error.Code == "resource_missing" {
+			return ErrSessionNotFound
+		}
+
+		return &envelope.Error
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,231 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"go-ambassador/src/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// stripeServer starts an httptest server standing in for the Stripe API and
+// returns an adapter pointed at it
+func stripeServer(t *testing.T, handler http.HandlerFunc) *Stripe {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test_123" {
+			t.Errorf("%s %s: authorization %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewStripe("sk_test_123", server.URL+"/")
+}
+
+// readForm parses the form encoded request body
+func readForm(t *testing.T, r *http.Request) url.Values {
+	t.Helper()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return form
+}
+
+func TestStripeCreateCheckoutSession(t *testing.T) {
+	stripe := stripeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/checkout/sessions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		form := readForm(t, r)
+		want := map[string]string{
+			"mode":                                "payment",
+			"client_reference_id":                 "42",
+			"success_url":                         "http://checkout/success",
+			"cancel_url":                          "http://checkout/error",
+			"line_items[0][price_data][currency]": Currency,
+			"line_items[0][price_data][product_data][name]": "Mug",
+			"line_items[0][price_data][unit_amount]":        "1250",
+			"line_items[0][quantity]":                       "2",
+			"line_items[1][price_data][product_data][name]": "Shirt",
+			"line_items[1][price_data][unit_amount]":        "2000",
+			"line_items[1][quantity]":                       "1",
+		}
+		for key, value := range want {
+			if form.Get(key) != value {
+				t.Errorf("%s = %q, want %q", key, form.Get(key), value)
+			}
+		}
+
+		io.WriteString(w, `{"id": "cs_test_1", "url": "https://checkout.stripe.test/cs_test_1"}`)
+	})
+
+	session, err := stripe.CreateCheckoutSession(context.Background(), SessionParams{
+		Reference: "42",
+		LineItems: []LineItem{
+			{Name: "Mug", UnitAmount: 1250, Quantity: 2},
+			{Name: "Shirt", UnitAmount: 2000, Quantity: 1},
+		},
+		SuccessURL: "http://checkout/success",
+		CancelURL:  "http://checkout/error",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if session.Id != "cs_test_1" || session.URL != "https://checkout.stripe.test/cs_test_1" {
+		t.Errorf("session %+v", session)
+	}
+}
+
+func TestStripeConfirm(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		paid   bool
+	}{
+		{"paid", "paid", true},
+		{"unpaid", "unpaid", false},
+		{"no payment required", "no_payment_required", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stripe := stripeServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/v1/checkout/sessions/cs_test_1" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+
+				io.WriteString(w, `{"id": "cs_test_1", "payment_status": "`+test.status+`", "payment_intent": "pi_1", "amount_total": 4500}`)
+			})
+
+			confirmation, err := stripe.Confirm(context.Background(), "cs_test_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Confirmation{SessionId: "cs_test_1", PaymentId: "pi_1", Paid: test.paid, AmountTotal: 4500}
+			if *confirmation != want {
+				t.Errorf("confirmation %+v, want %+v", *confirmation, want)
+			}
+		})
+	}
+}
+
+func TestStripeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		check  func(t *testing.T, err error)
+	}{
+		{
+			name:   "missing session",
+			status: http.StatusNotFound,
+			body:   `{"error": {"type": "invalid_request_error", "code": "resource_missing", "message": "No such checkout.session"}}`,
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, ErrSessionNotFound) {
+					t.Errorf("error %v, want ErrSessionNotFound", err)
+				}
+			},
+		},
+		{
+			name:   "api error",
+			status: http.StatusUnauthorized,
+			body:   `{"error": {"type": "invalid_request_error", "message": "Invalid API Key provided"}}`,
+			check: func(t *testing.T, err error) {
+				var stripeErr *StripeError
+				if !errors.As(err, &stripeErr) {
+					t.Fatalf("error %v, want a *StripeError", err)
+				}
+				if stripeErr.StatusCode != http.StatusUnauthorized || stripeErr.Message != "Invalid API Key provided" {
+					t.Errorf("error %+v", stripeErr)
+				}
+			},
+		},
+		{
+			name:   "malformed error",
+			status: http.StatusBadGateway,
+			body:   `<html>bad gateway</html>`,
+			check: func(t *testing.T, err error) {
+				var stripeErr *StripeError
+				if !errors.As(err, &stripeErr) || stripeErr.StatusCode != http.StatusBadGateway {
+					t.Errorf("error %v, want a *StripeError with status 502", err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stripe := stripeServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				io.WriteString(w, test.body)
+			})
+
+			_, err := stripe.Confirm(context.Background(), "cs_test_1")
+			test.check(t, err)
+		})
+	}
+}
+
+func TestStripeRefund(t *testing.T) {
+	stripe := stripeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/refunds" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+
+		form := readForm(t, r)
+		if form.Get("payment_intent") != "pi_1" || form.Get("amount") != "1500" {
+			t.Errorf("form %v", form)
+		}
+
+		io.WriteString(w, `{"id": "re_1", "payment_intent": "pi_1", "amount": 1500, "status": "succeeded"}`)
+	})
+
+	refund, err := stripe.Refund(context.Background(), "pi_1", 1500)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Refund{Id: "re_1", PaymentId: "pi_1", Amount: 1500, Status: "succeeded"}
+	if *refund != want {
+		t.Errorf("refund %+v, want %+v", *refund, want)
+	}
+}
+
+func TestSetup(t *testing.T) {
+	Setup(config.PaymentsConfig{Driver: "stripe", StripeSecretKey: "sk_test_123", CheckoutURL: "http://checkout/"})
+	if _, ok := Default.(*Stripe); !ok {
+		t.Errorf("stripe driver: provider %T", Default)
+	}
+	if CheckoutURL != "http://checkout" {
+		t.Errorf("checkout url %q", CheckoutURL)
+	}
+
+	Setup(config.PaymentsConfig{Driver: "fake"})
+	if _, ok := Default.(*Fake); !ok {
+		t.Errorf("fake driver: provider %T", Default)
+	}
+
+	// Anything but an explicit fake talks to Stripe, never silently to the fake
+	Setup(config.PaymentsConfig{})
+	if _, ok := Default.(*Stripe); !ok {
+		t.Errorf("no driver: provider %T, want the Stripe adapter", Default)
+	}
+}
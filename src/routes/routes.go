@@ -1 +1,113 @@
 package routes
+
+import (
+	"go-ambassador/src/controllers"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/util"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+)
+
+// Setup registers all API routes on the Fiber app
+// Admin and ambassador APIs live in separate groups; each group binds its
+// scope in IsAuthenticated, which rejects tokens minted for the other group
+func Setup(app *fiber.App) {
+	// Tag every request with an ID that is echoed in the X-Request-ID header
+	// and in error responses
+	app.Use(requestid.New())
+
+	api := app.Group("/api")
+
+	// Exchanges the refresh token cookie for new tokens, valid for both groups
+	api.Post("/refresh", controllers.Refresh)
+
+	// Password recovery and invitation acceptance, valid for both groups
+	api.Post("/forgot", controllers.Forgot)
+	api.Post("/reset", controllers.Reset)
+
+	// Email verification for newly registered accounts
+	api.Post("/verify", controllers.Verify)
+	api.Post("/verify/resend", controllers.ResendVerification)
+
+	// Admin API
+	// Self-registered admins have no role until one is assigned through
+	// PUT /users/:id; the first admin is created from ADMIN_EMAIL on start
+	admin := api.Group("/admin")
+	admin.Post("/register", controllers.AdminRegister)
+	admin.Post("/login", controllers.AdminLogin)
+
+	// Export downloads are authorized by the signed link instead of the cookie
+	admin.Get("/exports/:id/download", controllers.DownloadExport)
+
+	adminAuthenticated := admin.Group("", middlewares.IsAuthenticated(util.ScopeAdmin))
+	adminAuthenticated.Get("/user", controllers.User)
+	adminAuthenticated.Post("/logout", controllers.Logout)
+	adminAuthenticated.Put("/users/info", controllers.UpdateInfo)
+	adminAuthenticated.Put("/users/password", controllers.UpdatePassword)
+
+	adminAuthenticated.Get("/users", controllers.AllUsers)
+	adminAuthenticated.Post("/users", controllers.CreateUser)
+	adminAuthenticated.Get("/users/:id", controllers.GetUser)
+	adminAuthenticated.Put("/users/:id", controllers.UpdateUser)
+	adminAuthenticated.Delete("/users/:id", controllers.DeleteUser)
+	adminAuthenticated.Delete("/users/:id/sessions", controllers.RevokeUserSessions)
+
+	adminAuthenticated.Get("/roles", controllers.AllRoles)
+	adminAuthenticated.Post("/roles", controllers.CreateRole)
+	adminAuthenticated.Get("/roles/:id", controllers.GetRole)
+	adminAuthenticated.Put("/roles/:id", controllers.UpdateRole)
+	adminAuthenticated.Delete("/roles/:id", controllers.DeleteRole)
+
+	adminAuthenticated.Get("/permissions", controllers.AllPermissions)
+	adminAuthenticated.Post("/permissions", controllers.CreatePermission)
+	adminAuthenticated.Get("/permissions/:id", controllers.GetPermission)
+	adminAuthenticated.Put("/permissions/:id", controllers.UpdatePermission)
+	adminAuthenticated.Delete("/permissions/:id", controllers.DeletePermission)
+
+	adminAuthenticated.Get("/products", controllers.AllProducts)
+	adminAuthenticated.Post("/products", controllers.CreateProduct)
+	adminAuthenticated.Get("/products/:id", controllers.GetProduct)
+	adminAuthenticated.Put("/products/:id", controllers.UpdateProduct)
+	adminAuthenticated.Delete("/products/:id", controllers.DeleteProduct)
+
+	adminAuthenticated.Get("/orders", controllers.AllOrders)
+	adminAuthenticated.Post("/orders/:id/cancel", controllers.CancelOrder)
+	adminAuthenticated.Post("/orders/:id/refund", controllers.RefundOrder)
+	adminAuthenticated.Post("/export", controllers.Export)
+	adminAuthenticated.Post("/exports", controllers.CreateExportJob)
+	adminAuthenticated.Get("/exports/:id", controllers.GetExportJob)
+	adminAuthenticated.Get("/chart", controllers.Chart)
+	adminAuthenticated.Get("/analytics/sales", controllers.SalesAnalytics)
+
+	// Ambassador API
+	ambassador := api.Group("/ambassador")
+	ambassador.Post("/register", controllers.AmbassadorRegister)
+	ambassador.Post("/login", controllers.AmbassadorLogin)
+
+	// The product catalogue is public so ambassadors can browse before signing in
+	ambassador.Get("/products/frontend", controllers.ProductsFrontend)
+	ambassador.Get("/products/backend", controllers.ProductsBackend)
+
+	ambassadorAuthenticated := ambassador.Group("", middlewares.IsAuthenticated(util.ScopeAmbassador))
+	ambassadorAuthenticated.Get("/user", controllers.User)
+	ambassadorAuthenticated.Post("/logout", controllers.Logout)
+	ambassadorAuthenticated.Put("/users/info", controllers.UpdateInfo)
+	ambassadorAuthenticated.Put("/users/password", controllers.UpdatePassword)
+
+	ambassadorAuthenticated.Get("/links", controllers.Links)
+	ambassadorAuthenticated.Post("/links", controllers.CreateLink)
+	// The leaderboard lives at /api/ambassadors/rankings; this path is kept as an alias
+	ambassadorAuthenticated.Get("/rankings", controllers.Rankings)
+	ambassadorAuthenticated.Get("/stats", controllers.AmbassadorStats)
+
+	// Ambassador-wide resources, also for ambassador tokens only
+	ambassadors := api.Group("/ambassadors", middlewares.IsAuthenticated(util.ScopeAmbassador))
+	ambassadors.Get("/rankings", controllers.Rankings)
+
+	// Checkout routes are public and used by buyers following a link
+	checkout := api.Group("/checkout")
+	checkout.Get("/links/:code", controllers.GetLink)
+	checkout.Post("/orders", controllers.CreateOrder)
+	checkout.Post("/orders/confirm", controllers.ConfirmOrder)
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"go-ambassador/src/config"
+	"io"
+)
+
+// ErrNotFound is returned by Storage.Open when no file is stored under the key
+var ErrNotFound = errors.New("storage: file not found")
+
+// Storage is implemented by every file storage backend
+// Keys are slash separated relative paths such as exports/12.csv
+type Storage interface {
+	// Create returns a writer for the file stored under key
+	// The file only becomes visible once the writer is closed
+	Create(ctx context.Context, key string) (io.WriteCloser, error)
+	// Open returns a reader for the file stored under key or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the file stored under key; a missing file is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Default is the storage used by the export jobs
+var Default Storage
+
+// Setup selects the storage described by cfg
+// Local disk is the only driver so far; config validation rejects the others
+func Setup(cfg config.StorageConfig) {
+	Default = NewLocal(cfg.Dir)
+}
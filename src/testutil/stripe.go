@@ -0,0 +1,169 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-ambassador/src/payments"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Stripe is an httptest server standing in for the Stripe Checkout API
+// Sessions start unpaid until Pay is called, like a buyer leaving the hosted
+// page without paying
+type Stripe struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	sessions map[string]*stripeSession
+	refunded map[string]int64
+}
+
+// stripeSession is a checkout session as stored by the stand-in
+type stripeSession struct {
+	Id            string `json:"id"`
+	URL           string `json:"url"`
+	PaymentStatus string `json:"payment_status"`
+	PaymentIntent string `json:"payment_intent"`
+	AmountTotal   int64  `json:"amount_total"`
+}
+
+// NewStripe starts the stand-in and makes it the payment provider for the
+// duration of the test
+func NewStripe(t testing.TB) *Stripe {
+	t.Helper()
+
+	stripe := &Stripe{
+		sessions: map[string]*stripeSession{},
+		refunded: map[string]int64{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/checkout/sessions", stripe.createSession)
+	mux.HandleFunc("GET /v1/checkout/sessions/{id}", stripe.getSession)
+	mux.HandleFunc("POST /v1/refunds", stripe.refund)
+
+	stripe.server = httptest.NewServer(mux)
+	t.Cleanup(stripe.server.Close)
+
+	previous := payments.Default
+	payments.Default = payments.NewStripe("sk_test_stand_in", stripe.server.URL)
+	t.Cleanup(func() { payments.Default = previous })
+
+	return stripe
+}
+
+// Pay marks the session as paid
+func (s *Stripe) Pay(sessionId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionId]; ok {
+		session.PaymentStatus = "paid"
+	}
+}
+
+// Refunded returns the amount refunded on the payment so far
+func (s *Stripe) Refunded(paymentId string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.refunded[paymentId]
+}
+
+func (s *Stripe) createSession(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		stripeError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	var total int64
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("line_items[%d]", i)
+		if !r.PostForm.Has(prefix + "[quantity]") {
+			break
+		}
+
+		amount, _ := strconv.ParseInt(r.PostForm.Get(prefix+"[price_data][unit_amount]"), 10, 64)
+		quantity, _ := strconv.ParseInt(r.PostForm.Get(prefix+"[quantity]"), 10, 64)
+		total += amount * quantity
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := fmt.Sprintf("cs_test_%d", len(s.sessions)+1)
+	session := &stripeSession{
+		Id:            id,
+		URL:           "https://checkout.stripe.test/" + id,
+		PaymentStatus: "unpaid",
+		PaymentIntent: fmt.Sprintf("pi_test_%d", len(s.sessions)+1),
+		AmountTotal:   total,
+	}
+	s.sessions[id] = session
+
+	json.NewEncoder(w).Encode(session)
+}
+
+func (s *Stripe) getSession(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[r.PathValue("id")]
+	if !ok {
+		stripeError(w, http.StatusNotFound, "resource_missing", "No such checkout.session")
+		return
+	}
+
+	json.NewEncoder(w).Encode(session)
+}
+
+func (s *Stripe) refund(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		stripeError(w, http.StatusBadRequest, "", err.Error())
+		return
+	}
+
+	paymentId := r.PostForm.Get("payment_intent")
+	amount, _ := strconv.ParseInt(r.PostForm.Get("amount"), 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.PaymentIntent != paymentId || session.PaymentStatus != "paid" {
+			continue
+		}
+
+		if amount <= 0 || s.refunded[paymentId]+amount > session.AmountTotal {
+			stripeError(w, http.StatusBadRequest, "amount_too_large", "Refund amount is greater than the remaining charge")
+			return
+		}
+
+		s.refunded[paymentId] += amount
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":             fmt.Sprintf("re_test_%d", len(s.refunded)),
+			"payment_intent": paymentId,
+			"amount":         amount,
+			"status":         "succeeded",
+		})
+		return
+	}
+
+	stripeError(w, http.StatusNotFound, "resource_missing", "No such payment_intent")
+}
+
+// stripeError writes an error in Stripe's envelope
+func stripeError(w http.ResponseWriter, status int, code string, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{
+			"type":    "invalid_request_error",
+			"code":    code,
+			"message": message,
+		},
+	})
+}
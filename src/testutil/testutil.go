@@ -0,0 +1,287 @@
+// Package testutil sets up the database, Redis and HTTP app used by tests
+// Everything runs in process: SQLite stands in for MySQL and miniredis for
+// Redis, so the tests need no external services
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-ambassador/src/cache"
+	"go-ambassador/src/config"
+	"go-ambassador/src/database"
+	"go-ambassador/src/export"
+	"go-ambassador/src/mail"
+	"go-ambassador/src/middlewares"
+	"go-ambassador/src/models"
+	"go-ambassador/src/payments"
+	"go-ambassador/src/routes"
+	"go-ambassador/src/storage"
+	"go-ambassador/src/util"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v3"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// jwtSecret signs the tokens issued during tests
+const jwtSecret = "test-secret-0123456789"
+
+// Password is the password of every user created by CreateUser
+const Password = "password"
+
+// testPassword is Password hashed with the lowest bcrypt cost
+var testPassword, _ = bcrypt.GenerateFromPassword([]byte(Password), bcrypt.MinCost)
+
+// databases numbers the in-memory databases so every test gets its own
+var databases atomic.Int64
+
+// DB points database.DB at a new in-memory SQLite database for the duration of
+// the test, with every table migrated and the permissions seeded
+func DB(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	// Each connection to a plain :memory: database sees an empty database, so
+	// a named shared-cache database is used instead
+	dsn := fmt.Sprintf("file:test%d?mode=memory&cache=shared", databases.Add(1))
+
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+		TranslateError:                           true,
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+
+	previous := database.DB
+	database.DB = db
+
+	t.Cleanup(func() {
+		database.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := database.AutoMigrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	if err := database.SeedPermissions(); err != nil {
+		t.Fatalf("seeding database: %v", err)
+	}
+
+	return db
+}
+
+// Redis points database.Cache and the Redis cache store at a new in-process
+// Redis for the duration of the test
+func Redis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+
+	previousClient, previousStore := database.Cache, cache.Default
+	database.Cache = redis.NewClient(&redis.Options{Addr: server.Addr()})
+	cache.Setup(config.CacheConfig{Driver: "redis"}, database.Cache)
+
+	t.Cleanup(func() {
+		database.Cache.Close()
+		database.Cache, cache.Default = previousClient, previousStore
+	})
+
+	return server
+}
+
+// Env is the application wired up for a test
+type Env struct {
+	App      *fiber.App
+	DB       *gorm.DB
+	Redis    *miniredis.Miniredis
+	Mail     *mail.Memory
+	Payments *payments.Fake
+}
+
+// Setup builds the application with every dependency in process: SQLite,
+// miniredis, the memory mailer, the fake payment provider and local storage
+// in a temporary directory
+func Setup(t testing.TB) *Env {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.JWT.Secret = jwtSecret
+	cfg.Mail.Driver = "memory"
+	cfg.Storage.Dir = t.TempDir()
+
+	env := &Env{
+		DB:    DB(t),
+		Redis: Redis(t),
+	}
+
+	util.SetupJWT(cfg.JWT)
+	util.SetupCookies(cfg.Cookie)
+	mail.Setup(cfg.Mail, cfg.AppURL)
+	storage.Setup(cfg.Storage)
+	export.Setup(cfg.Exports)
+
+	env.Mail = mail.Default.(*mail.Memory)
+
+	env.Payments = payments.NewFake()
+	payments.Default = env.Payments
+	payments.CheckoutURL = cfg.Payments.CheckoutURL
+
+	env.App = fiber.New(fiber.Config{ErrorHandler: middlewares.ErrorHandler})
+	routes.Setup(env.App)
+
+	return env
+}
+
+// CreateUser stores user, defaulting the fields a valid account needs
+// The password is set to Password with a low bcrypt cost to keep tests fast
+func CreateUser(t testing.TB, user models.User) models.User {
+	t.Helper()
+
+	if user.FirstName == "" {
+		user.FirstName = "Test"
+	}
+	if user.LastName == "" {
+		user.LastName = "User"
+	}
+	if user.Email == "" {
+		user.Email = fmt.Sprintf("user%d@example.com", databases.Add(1))
+	}
+	if user.Password == nil {
+		user.Password = testPassword
+	}
+
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("creating user: %v", err)
+	}
+
+	return user
+}
+
+// Client sends requests to the app and keeps the cookies it receives, like a
+// browser would
+type Client struct {
+	t       testing.TB
+	app     *fiber.App
+	cookies map[string]string
+}
+
+// Client returns a client without cookies
+func (env *Env) Client(t testing.TB) *Client {
+	return &Client{t: t, app: env.App, cookies: map[string]string{}}
+}
+
+// LoginAs starts a session for user on the API group of scope, without going
+// through the login endpoint
+func (env *Env) LoginAs(t testing.TB, user models.User, scope string) *Client {
+	t.Helper()
+
+	familyId, err := util.RandomCode(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := models.Session{
+		UserId:    user.Id,
+		FamilyId:  familyId,
+		Scope:     scope,
+		TokenHash: util.HashToken(familyId),
+		ExpiresAt: time.Now().Add(util.RefreshTokenTTL()),
+	}
+
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatalf("creating session: %v", err)
+	}
+
+	token, err := util.GenerateJWT(strconv.Itoa(int(user.Id)), scope, familyId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := env.Client(t)
+	client.cookies[util.AuthCookieName] = token
+
+	return client
+}
+
+// Cookie returns the value of the cookie the client holds, or "" without one
+func (c *Client) Cookie(name string) string {
+	return c.cookies[name]
+}
+
+// SetCookie replaces a cookie of the client, for example to replay a token
+func (c *Client) SetCookie(name string, value string) {
+	c.cookies[name] = value
+}
+
+// Response is a recorded response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Decode unmarshals the JSON body into v
+func (r *Response) Decode(t testing.TB, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decoding %s: %v", r.Body, err)
+	}
+}
+
+// Do sends a request with body encoded as JSON; a nil body sends none
+func (c *Client) Do(method string, path string, body interface{}) *Response {
+	c.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	res, err := c.app.Test(req, fiber.TestConfig{Timeout: 0})
+	if err != nil {
+		c.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer res.Body.Close()
+
+	for _, cookie := range res.Cookies() {
+		if cookie.MaxAge < 0 || cookie.Value == "" {
+			delete(c.cookies, cookie.Name)
+			continue
+		}
+		c.cookies[cookie.Name] = cookie.Value
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	return &Response{Status: res.StatusCode, Header: res.Header, Body: data}
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// codeAlphabet contains the characters allowed in generated codes
+// Only URL-safe alphanumerics are used so codes can be embedded in links as-is
+const codeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// RandomCode returns a cryptographically random alphanumeric string of length n
+// With 62 possible characters per position, 12 characters give ~71 bits of entropy
+func RandomCode(n int) (string, error) {
+	code := make([]byte, n)
+	max := big.NewInt(int64(len(codeAlphabet)))
+
+	for i := range code {
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = codeAlphabet[index.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"go-ambassador/src/config"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// AuthCookieName is the name of the cookie carrying the JWT
+const AuthCookieName = "jwt"
+
+// RefreshCookieName is the name of the cookie carrying the refresh token
+const RefreshCookieName = "refresh_token"
+
+// cookieConfig holds the attributes applied to every auth cookie
+var cookieConfig config.CookieConfig
+
+// SetupCookies configures the attributes of the auth cookie
+func SetupCookies(cfg config.CookieConfig) {
+	cookieConfig = cfg
+}
+
+// AuthCookie builds the HTTP-only cookie carrying the JWT
+// Pass an empty value and a past expiry to clear the cookie
+func AuthCookie(value string, expires time.Time) *fiber.Cookie {
+	return &fiber.Cookie{
+		Name:     AuthCookieName,
+		Value:    value,
+		Expires:  expires,
+		Domain:   cookieConfig.Domain,
+		Secure:   cookieConfig.Secure,
+		SameSite: cookieConfig.SameSite,
+		HTTPOnly: true,
+	}
+}
+
+// RefreshCookie builds the HTTP-only cookie carrying the refresh token
+// It is only sent to /api so it never leaves the API origin paths
+// Pass an empty value and a past expiry to clear the cookie
+func RefreshCookie(value string, expires time.Time) *fiber.Cookie {
+	cookie := AuthCookie(value, expires)
+	cookie.Name = RefreshCookieName
+	cookie.Path = "/api"
+	return cookie
+}
@@ -0,0 +1,109 @@
+package util
+
+import (
+	"go-ambassador/src/config"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// secretKey is used to sign and verify JWT tokens
+var secretKey []byte
+
+// accessTokenTTL and refreshTokenTTL are the token lifetimes set by SetupJWT
+var accessTokenTTL, refreshTokenTTL time.Duration
+
+// Claims are the JWT claims issued on login
+// Issuer holds the user ID, Scope the API group the token was minted for
+// and SessionId the session family that must still be active server-side
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope     string `json:"scope"`
+	SessionId string `json:"sid"`
+}
+
+// SetupJWT configures the signing secret and token lifetimes
+// It must be called before issuing tokens
+func SetupJWT(cfg config.JWTConfig) {
+	secretKey = []byte(cfg.Secret)
+	accessTokenTTL = cfg.AccessTokenTTL.Std()
+	refreshTokenTTL = cfg.RefreshTokenTTL.Std()
+}
+
+// AccessTokenTTL returns how long an access token stays valid
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// RefreshTokenTTL returns how long a refresh token stays valid
+func RefreshTokenTTL() time.Duration {
+	return refreshTokenTTL
+}
+
+// GenerateJWT creates a short-lived access token for the given issuer (the user ID),
+// scope and session family
+func GenerateJWT(issuer string, scope string, sessionId string) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+		},
+		Scope:     scope,
+		SessionId: sessionId,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secretKey)
+}
+
+// ParseClaims validates the token and returns all of its claims
+func ParseClaims(cookie string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(cookie, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+		return secretKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return token.Claims.(*Claims), nil
+}
+
+// ParseJWT validates the token and returns its issuer (the user ID)
+func ParseJWT(cookie string) (string, error) {
+	claims, err := ParseClaims(cookie)
+	if err != nil {
+		return "", err
+	}
+
+	return claims.Issuer, nil
+}
+
+// GenerateVerificationJWT creates a signed email verification token
+// The email is embedded so the link stops working if the address changes
+func GenerateVerificationJWT(issuer string, email string, ttl time.Duration) (string, error) {
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   email,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+		Scope: ScopeVerifyEmail,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secretKey)
+}
+
+// ParseVerificationJWT validates an email verification token and returns its
+// issuer (the user ID) and email
+func ParseVerificationJWT(token string) (string, string, error) {
+	claims, err := ParseClaims(token)
+	if err != nil {
+		return "", "", err
+	}
+
+	if claims.Scope != ScopeVerifyEmail {
+		return "", "", jwt.ErrTokenInvalidClaims
+	}
+
+	return claims.Issuer, claims.Subject, nil
+}
@@ -0,0 +1,10 @@
+package util
+
+// Scopes identify which API a session belongs to
+// ScopeVerifyEmail marks email verification tokens, which are never accepted
+// as access tokens since no route group requires it
+const (
+	ScopeAdmin       = "admin"
+	ScopeAmbassador  = "ambassador"
+	ScopeVerifyEmail = "verify_email"
+)
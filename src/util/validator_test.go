@@ -0,0 +1,234 @@
+package util
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type testItem struct {
+	Id       uint `json:"product_id" validate:"required"`
+	Quantity int  `json:"quantity" validate:"gt=0"`
+}
+
+type testRequest struct {
+	Name            string     `json:"name" validate:"required,min=3,max=5"`
+	Email           string     `json:"email" validate:"omitempty,email"`
+	Website         string     `json:"website" validate:"omitempty,url"`
+	Password        string     `json:"password"`
+	PasswordConfirm string     `json:"password_confirm" validate:"eqfield=Password"`
+	Sort            string     `json:"sort" validate:"omitempty,oneof=asc desc"`
+	Age             int        `json:"age" validate:"gte=18,max=130"`
+	Tags            []string   `json:"tags" validate:"min=1,max=2"`
+	Code            string     `json:"code" validate:"omitempty,alphanum"`
+	Internal        string     `json:"-" validate:"omitempty,len=2"`
+	Untagged        string     `validate:"omitempty,uppercase"`
+	Items           []testItem `json:"items" validate:"dive"`
+}
+
+// valid returns a request that passes every rule
+func valid() testRequest {
+	return testRequest{
+		Name:            "Mug",
+		Password:        "secret",
+		PasswordConfirm: "secret",
+		Age:             30,
+		Tags:            []string{"a"},
+		Items:           []testItem{{Id: 1, Quantity: 1}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r *testRequest)
+		want   []FieldError
+	}{
+		{
+			name:   "valid",
+			modify: func(r *testRequest) {},
+		},
+		{
+			name:   "required",
+			modify: func(r *testRequest) { r.Name = "" },
+			want:   []FieldError{{Field: "name", Message: "is required"}},
+		},
+		{
+			name:   "string min",
+			modify: func(r *testRequest) { r.Name = "ab" },
+			want:   []FieldError{{Field: "name", Message: "must be at least 3 characters long"}},
+		},
+		{
+			name:   "string max",
+			modify: func(r *testRequest) { r.Name = "abcdef" },
+			want:   []FieldError{{Field: "name", Message: "must be at most 5 characters long"}},
+		},
+		{
+			name:   "email",
+			modify: func(r *testRequest) { r.Email = "nope" },
+			want:   []FieldError{{Field: "email", Message: "must be a valid email address"}},
+		},
+		{
+			name:   "url",
+			modify: func(r *testRequest) { r.Website = "nope" },
+			want:   []FieldError{{Field: "website", Message: "must be a valid URL"}},
+		},
+		{
+			name:   "eqfield",
+			modify: func(r *testRequest) { r.PasswordConfirm = "other" },
+			want:   []FieldError{{Field: "password_confirm", Message: "must match password"}},
+		},
+		{
+			name:   "oneof",
+			modify: func(r *testRequest) { r.Sort = "up" },
+			want:   []FieldError{{Field: "sort", Message: "must be one of: asc, desc"}},
+		},
+		{
+			name:   "gte",
+			modify: func(r *testRequest) { r.Age = 17 },
+			want:   []FieldError{{Field: "age", Message: "must be greater than or equal to 18"}},
+		},
+		{
+			name:   "number max",
+			modify: func(r *testRequest) { r.Age = 131 },
+			want:   []FieldError{{Field: "age", Message: "must be at most 130"}},
+		},
+		{
+			name:   "slice min",
+			modify: func(r *testRequest) { r.Tags = nil },
+			want:   []FieldError{{Field: "tags", Message: "must contain at least 1 items"}},
+		},
+		{
+			name:   "slice max",
+			modify: func(r *testRequest) { r.Tags = []string{"a", "b", "c"} },
+			want:   []FieldError{{Field: "tags", Message: "must contain at most 2 items"}},
+		},
+		{
+			name:   "unknown rule",
+			modify: func(r *testRequest) { r.Code = "a-b" },
+			want:   []FieldError{{Field: "code", Message: `failed the "alphanum" rule`}},
+		},
+		{
+			name:   "untagged field keeps its Go name",
+			modify: func(r *testRequest) { r.Untagged = "abc" },
+			want:   []FieldError{{Field: "Untagged", Message: `failed the "uppercase" rule`}},
+		},
+		{
+			name:   "hidden field keeps its Go name",
+			modify: func(r *testRequest) { r.Internal = "abc" },
+			want:   []FieldError{{Field: "Internal", Message: `failed the "len" rule`}},
+		},
+		{
+			name: "nested",
+			modify: func(r *testRequest) {
+				r.Items = []testItem{{Id: 1, Quantity: 1}, {Quantity: 0}}
+			},
+			want: []FieldError{
+				{Field: "items[1].product_id", Message: "is required"},
+				{Field: "items[1].quantity", Message: "must be greater than 0"},
+			},
+		},
+		{
+			name: "several fields",
+			modify: func(r *testRequest) {
+				r.Name = ""
+				r.Age = 0
+			},
+			want: []FieldError{
+				{Field: "name", Message: "is required"},
+				{Field: "age", Message: "must be greater than or equal to 18"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := valid()
+			tt.modify(&request)
+
+			if got := Validate(request); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidInput(t *testing.T) {
+	// Anything but a struct is reported without a field
+	got := Validate("not a struct")
+	if len(got) != 1 || got[0].Field != "" || got[0].Message == "" {
+		t.Errorf("Validate() = %+v, want a single error without a field", got)
+	}
+}
+
+// fieldError returns the first validator error for request
+func fieldError(t *testing.T, request interface{}) validator.FieldError {
+	t.Helper()
+
+	var errs validator.ValidationErrors
+	if !errors.As(validate.Struct(request), &errs) {
+		t.Fatal("expected validation errors")
+	}
+
+	return errs[0]
+}
+
+func TestFieldPath(t *testing.T) {
+	type inner struct {
+		Value string `json:"value" validate:"required"`
+	}
+
+	type outer struct {
+		Top    string  `json:"top" validate:"required"`
+		Nested inner   `json:"nested"`
+		List   []inner `json:"list" validate:"dive"`
+	}
+
+	tests := []struct {
+		name    string
+		request outer
+		want    string
+	}{
+		{"top level", outer{}, "top"},
+		{"nested struct", outer{Top: "x"}, "nested.value"},
+		{"slice element", outer{Top: "x", Nested: inner{"x"}, List: []inner{{"x"}, {""}}}, "list[1].value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldPath(fieldError(t, tt.request)); got != tt.want {
+				t.Errorf("fieldPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldMessage(t *testing.T) {
+	type bounds struct {
+		Text   string         `json:"text" validate:"min=2"`
+		Items  []int          `json:"items" validate:"max=1"`
+		Lookup map[string]int `json:"lookup" validate:"min=1"`
+		Count  uint           `json:"count" validate:"gt=3"`
+	}
+
+	tests := []struct {
+		name    string
+		request bounds
+		want    string
+	}{
+		{"string", bounds{Text: "a"}, "must be at least 2 characters long"},
+		{"slice", bounds{Text: "ab", Items: []int{1, 2}}, "must contain at most 1 items"},
+		{"map", bounds{Text: "ab"}, "must contain at least 1 items"},
+		{"number", bounds{Text: "ab", Lookup: map[string]int{"a": 1}}, "must be greater than 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldMessage(fieldError(t, tt.request)); got != tt.want {
+				t.Errorf("fieldMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}